@@ -1,10 +1,15 @@
 package main
 
 import (
+	"log"
+
 	"github.com/ivanov-nikolay/distributed_calculator/internal/app/agent"
 )
 
 func main() {
-	a := agent.NewApplicationAgent()
+	a, err := agent.NewApplicationAgent()
+	if err != nil {
+		log.Fatalf("error loading config: %v", err)
+	}
 	a.RunApplicationAgent()
 }
@@ -1,10 +1,15 @@
 package main
 
 import (
+	"log"
+
 	"github.com/ivanov-nikolay/distributed_calculator/internal/app/orchestrator"
 )
 
 func main() {
-	o := orchestrator.NewApplicationOrchestrator()
+	o, err := orchestrator.NewApplicationOrchestrator()
+	if err != nil {
+		log.Fatalf("error loading config: %v", err)
+	}
 	o.RunApplicationOrchestrator()
 }
@@ -0,0 +1,181 @@
+package orchestrator
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ivanov-nikolay/distributed_calculator/internal/models"
+)
+
+var (
+	// agents зарегистрированные агенты по ID; доступ синхронизируется taskMutex
+	agents = make(map[string]models.Agent)
+	// heartbeatTimeout время без сигналов активности, после которого агент считается недоступным,
+	// а выданные ему задачи возвращаются в очередь
+	heartbeatTimeout = 15 * time.Second
+)
+
+// agentsPage страница администратора со списком агентов
+var agentsPage = template.Must(template.New("agents").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Агенты</title></head>
+<body>
+<h1>Агенты</h1>
+<table border="1">
+<tr><th>ID</th><th>Хост</th><th>Версия</th><th>Вычислители</th><th>Состояние</th><th>Последний сигнал</th><th>В работе</th><th>Выполнено</th></tr>
+{{range .}}<tr><td>{{.ID}}</td><td>{{.Hostname}}</td><td>{{.Version}}</td><td>{{.ComputingPower}}</td><td>{{if .Alive}}активен{{else}}недоступен{{end}}</td><td>{{.LastHeartbeat.Format "2006-01-02 15:04:05"}}</td><td>{{.InFlight}}</td><td>{{.Completed}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+// SetHeartbeatTimeout задает время без сигналов активности, после которого агент считается недоступным
+func SetHeartbeatTimeout(timeout time.Duration) {
+	heartbeatTimeout = timeout
+}
+
+// countCompleted учитывает принятый результат задачи в статистике агента.
+// Вызывается при заблокированном taskMutex
+func countCompleted(agent string) {
+	if a, registered := agents[agent]; registered {
+		a.Completed++
+		agents[agent] = a
+	}
+}
+
+// listAgents возвращает зарегистрированных агентов, упорядоченных по ID, с их состоянием на момент now
+func listAgents(now time.Time) []models.Agent {
+	taskMutex.Lock()
+	defer taskMutex.Unlock()
+
+	list := make([]models.Agent, 0, len(agents))
+	for _, a := range agents {
+		a.Alive = now.Sub(a.LastHeartbeat) <= heartbeatTimeout
+		for _, l := range leases {
+			if _, holder := l.holders[a.ID]; holder {
+				a.InFlight++
+			}
+		}
+		list = append(list, a)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].ID < list[j].ID
+	})
+	return list
+}
+
+// ReapLostAgents возвращает в очередь задачи агентов, от которых к моменту now не было сигнала активности
+// дольше допустимого времени (например, агент потерял связь с оркестратором), учитывая их как неудачную
+// попытку; возвращает количество таких задач
+func ReapLostAgents(now time.Time) int {
+	taskMutex.Lock()
+	defer taskMutex.Unlock()
+
+	count := 0
+	for _, a := range agents {
+		if now.Sub(a.LastHeartbeat) <= heartbeatTimeout {
+			continue
+		}
+		for id, l := range leases {
+			if _, holder := l.holders[a.ID]; !holder {
+				continue
+			}
+			if _, completed := completedTasks[id]; completed {
+				releaseLease(id, a.ID)
+			} else {
+				failTask(l.task, a.ID, "agent heartbeat missed", now)
+			}
+			count++
+		}
+	}
+	return count
+}
+
+// HandleAgents обработчик http-запроса: GET возвращает список агентов, POST регистрирует агента
+// (повторная регистрация обновляет описание агента и сохраняет его статистику)
+func HandleAgents(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed) // 405
+	case http.MethodGet:
+		w.WriteHeader(http.StatusOK) // 200
+		_ = json.NewEncoder(w).Encode(map[string][]models.Agent{"agents": listAgents(time.Now())})
+	case http.MethodPost:
+		var registration models.Agent
+		if err := json.NewDecoder(r.Body).Decode(&registration); err != nil {
+			http.Error(w, "invalid data", http.StatusUnprocessableEntity) // 422
+			return
+		}
+		if registration.ID == "" || strings.Contains(registration.ID, "/") || registration.ComputingPower < 1 {
+			http.Error(w, "invalid agent", http.StatusUnprocessableEntity) // 422
+			return
+		}
+
+		now := time.Now()
+		taskMutex.Lock()
+		a, registered := agents[registration.ID]
+		if !registered {
+			a = models.Agent{ID: registration.ID, RegisteredAt: now}
+		}
+		a.Hostname = registration.Hostname
+		a.Version = registration.Version
+		a.ComputingPower = registration.ComputingPower
+		a.LastHeartbeat = now
+		agents[a.ID] = a
+		taskMutex.Unlock()
+
+		if registered {
+			w.WriteHeader(http.StatusOK) // 200
+		} else {
+			w.WriteHeader(http.StatusCreated) // 201
+		}
+		_ = json.NewEncoder(w).Encode(map[string]models.Agent{"agent": a})
+	}
+}
+
+// HandleAgentHeartbeat обработчик http-запроса POST /internal/agents/{id}/heartbeat, принимает сигнал
+// активности агента; незарегистрированный агент должен зарегистрироваться заново
+func HandleAgentHeartbeat(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed) // 405
+		return
+	}
+
+	path := r.URL.Path[len("/internal/agents/"):]
+	id, found := strings.CutSuffix(path, "/heartbeat")
+	if !found {
+		http.Error(w, "not found", http.StatusNotFound) // 404
+		return
+	}
+
+	taskMutex.Lock()
+	defer taskMutex.Unlock()
+
+	a, registered := agents[id]
+	if !registered {
+		http.Error(w, "agent not registered", http.StatusNotFound) // 404
+		return
+	}
+	a.LastHeartbeat = time.Now()
+	agents[id] = a
+
+	w.WriteHeader(http.StatusOK) // 200
+}
+
+// HandleAgentsPage обработчик http-запроса, возвращает страницу администратора со списком агентов
+func HandleAgentsPage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed) // 405
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := agentsPage.Execute(w, listAgents(time.Now())); err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError) // 500
+		return
+	}
+}
@@ -0,0 +1,81 @@
+package orchestrator
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/ivanov-nikolay/distributed_calculator/internal/models"
+)
+
+// HandleExpressionByID обработчик http-запросов к выражению по ID: GET возвращает описание
+// выражения, DELETE отменяет его вычисление
+func HandleExpressionByID(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodDelete:
+		HandleCancelExpression(w, r)
+	default:
+		HandleGetExpressionByID(w, r)
+	}
+}
+
+// HandleCancelExpression обработчик http-запроса, отменяет вычисление математического выражения:
+// выражение получает статус "cancelled", его задачи снимаются с выполнения, а их результаты,
+// полученные позже, отбрасываются
+func HandleCancelExpression(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed) // 405
+		return
+	}
+
+	id := r.URL.Path[len("/api/v1/expressions/"):]
+	expressionMutex.Lock()
+	expr, exists := expressions[id]
+	if !exists {
+		expressionMutex.Unlock()
+		http.Error(w, "expression not found", http.StatusNotFound) // 404
+		return
+	}
+	status := expr.Status
+	if !evaluating[id] || expr.Transition(models.StatusExpressionCancelled, "", time.Now()) != nil {
+		expressionMutex.Unlock()
+		http.Error(w, "expression is already "+status, http.StatusConflict) // 409
+		return
+	}
+
+	expressions[id] = expr
+	delete(evaluating, id)
+	cancel := cancels[id]
+	expressionMutex.Unlock()
+
+	// отмена останавливает вычисление выражения: ожидающие задачи снимаются с выполнения
+	cancel()
+	// задачи выражения убираются из очереди до ответа, чтобы агенты не получили их после отмены;
+	// задачи, опубликованные позже, не попадут в очередь, так как вычисление уже отменено
+	taskMutex.Lock()
+	for _, taskID := range expressionTasks[id] {
+		tasks.Remove(taskID)
+	}
+	taskMutex.Unlock()
+
+	w.WriteHeader(http.StatusOK) // 200
+	err := json.NewEncoder(w).Encode(map[string]models.Expression{"expression": expr})
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError) // 500
+		return
+	}
+}
+
+// discardTask снимает задачу с выполнения: удаляет ее из очереди и аренды и отмечает выполненной,
+// чтобы запоздавший результат агента был принят, но не учтен. Вызывается при заблокированном taskMutex
+func discardTask(id string) {
+	completedTasks[id] = struct{}{}
+	tasks.Remove(id)
+	delete(leases, id)
+	delete(failedAttempts, id)
+	delete(disputes, id)
+
+	waiterMutex.Lock()
+	delete(waiters, id)
+	waiterMutex.Unlock()
+}
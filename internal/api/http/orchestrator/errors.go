@@ -0,0 +1,73 @@
+package orchestrator
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/ivanov-nikolay/distributed_calculator/internal/models"
+	"github.com/ivanov-nikolay/distributed_calculator/internal/service"
+)
+
+// snippetRadius количество символов выражения, показываемых по обе стороны от места ошибки
+const snippetRadius = 20
+
+// writeParseError отправляет ошибку разбора выражения в виде JSON со статусом 422
+func writeParseError(w http.ResponseWriter, expr string, err error) {
+	var parseErr *service.ParseError
+	if !errors.As(err, &parseErr) {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity) // 422
+		return
+	}
+
+	position := utf8.RuneCountInString(expr[:parseErr.Pos])
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnprocessableEntity) // 422
+	_ = json.NewEncoder(w).Encode(map[string]models.ExpressionError{
+		"error": {
+			Code:     parseErr.Code,
+			Message:  parseErr.Message,
+			Position: position,
+			Snippet:  caretSnippet(expr, position),
+		},
+	})
+}
+
+// errorReason формирует причину ошибки вычисления выражения expr: позиция операции, задача которой
+// завершилась ошибкой, указывается в символах, как и в ошибках разбора
+func errorReason(expr string, err error) string {
+	var taskErr *service.TaskError
+	if !errors.As(err, &taskErr) {
+		return err.Error()
+	}
+	return fmt.Sprintf("%v at position %d", taskErr.Err, utf8.RuneCountInString(expr[:taskErr.Pos]))
+}
+
+// caretSnippet формирует фрагмент выражения вокруг позиции position (в символах)
+// и строку с указателем "^" под ней
+func caretSnippet(expr string, position int) string {
+	runes := []rune(expr)
+	// управляющие символы заменяются пробелами, чтобы указатель оставался под нужным символом
+	for i, char := range runes {
+		if unicode.IsSpace(char) {
+			runes[i] = ' '
+		}
+	}
+
+	start, end := max(0, position-snippetRadius), min(len(runes), position+snippetRadius)
+	prefix, suffix := "", ""
+	if start > 0 {
+		prefix = "..."
+	}
+	if end < len(runes) {
+		suffix = "..."
+	}
+
+	line := prefix + string(runes[start:end]) + suffix
+	caret := strings.Repeat(" ", len(prefix)+position-start) + "^"
+	return line + "\n" + caret
+}
@@ -0,0 +1,85 @@
+package orchestrator
+
+import (
+	"context"
+	"time"
+
+	"github.com/ivanov-nikolay/distributed_calculator/internal/models"
+)
+
+const (
+	// maxTaskWait наибольшее время, на которое агент может отложить ответ на запрос задачи
+	maxTaskWait = time.Minute
+	// maxTaskBatch наибольшее количество задач, выдаваемых агенту за один запрос
+	maxTaskBatch = 100
+)
+
+// taskReady закрывается при постановке задачи в очередь, чтобы разбудить ожидающих задачу агентов,
+// и заменяется новым каналом; доступ синхронизируется taskMutex
+var taskReady = make(chan struct{})
+
+// pushTask ставит задачу в очередь и будит агентов, ожидающих задачу.
+// Вызывается при заблокированном taskMutex
+func pushTask(task models.Task) {
+	tasks.Push(task)
+	close(taskReady)
+	taskReady = make(chan struct{})
+}
+
+// awaitTasks извлекает из очереди до limit задач, операции которых поддерживает агент agent
+// (operations, nil — все операции), и выдает их в аренду агенту; задача, которой нужны еще реплики,
+// возвращается в очередь, а задача, реплику которой агент уже получил, и задача, операцию которой агент
+// не поддерживает, остаются на своих местах в очереди. Если очередь пуста, ожидает появления задачи
+// не дольше wait или до отмены ctx (агент закрыл соединение)
+func awaitTasks(ctx context.Context, agent string, operations map[string]bool, wait time.Duration, limit int) []models.Task {
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	// пока агент ожидает задачу, его операции учитываются при поиске задач, которые не может вычислить ни один агент
+	taskMutex.Lock()
+	startPolling(agent, operations, time.Now())
+	taskMutex.Unlock()
+	defer func() {
+		taskMutex.Lock()
+		stopPolling(agent, time.Now())
+		taskMutex.Unlock()
+	}()
+
+	available := func(task models.Task) bool {
+		return canTake(task, agent) && canCompute(task, operations)
+	}
+	for {
+		var fetched, replicated []models.Task
+		taskMutex.Lock()
+		now := time.Now()
+		// очередь не просматривается, если в ней нет задач с операциями, которые поддерживает агент
+		for len(fetched) < limit && tasks.offers(operations) {
+			task, exists := tasks.PopFirst(available)
+			if !exists {
+				break
+			}
+			leaseTask(task, agent, now)
+			fetched = append(fetched, task)
+			if missingReplicas(task) > 0 {
+				replicated = append(replicated, task)
+			}
+		}
+		for _, task := range replicated {
+			pushTask(task)
+		}
+		ready := taskReady
+		taskMutex.Unlock()
+
+		if len(fetched) > 0 {
+			return fetched
+		}
+		select {
+		case <-ready:
+			// задачу мог забрать другой агент, поэтому очередь проверяется снова
+		case <-timer.C:
+			return nil
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
@@ -1,40 +1,80 @@
 package orchestrator
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"math"
 	"net/http"
 	"strconv"
-	"strings"
 	"sync"
 	"time"
 
+	"github.com/ivanov-nikolay/distributed_calculator/internal/ast"
 	"github.com/ivanov-nikolay/distributed_calculator/internal/models"
+	"github.com/ivanov-nikolay/distributed_calculator/internal/queue"
 	"github.com/ivanov-nikolay/distributed_calculator/internal/service"
 )
 
+// maxRequestBody наибольший размер тела запроса на вычисление выражения в байтах
+const maxRequestBody = 1 << 20
+
 var (
 	// expressions хранилище математических выражений
 	expressions = make(map[string]models.Expression)
-	// tasks хранилище задач
-	tasks = make(map[string]models.Task)
-	// results хранилище результатов задач
-	results = make(map[string]float64)
+	// tasks очередь задач, ожидающих выдачи агентам
+	tasks = newRoutedQueue(queue.NewPriority(0, time.Now, func() queue.Queue { return queue.NewFIFO() }))
+	// waiters каналы ожидающих результата задач, по одному на каждую опубликованную задачу
+	waiters = make(map[string]chan models.TaskResult)
+	// evaluating ID выражений, вычисление которых еще не завершено
+	evaluating = make(map[string]bool)
+	// cancels функции отмены вычисления выражений, вычисление которых еще не завершено
+	cancels = make(map[string]context.CancelFunc)
 	// expressionID
 	expressionID = 0
 	// taskID
 	taskID = 0
-	// operationTimes
+	// operationTimes время выполнения математических операций в миллисекундах
 	operationTimes = map[string]int{}
+	// maxExpressionTimeout наибольшее время вычисления выражения, оно же время по умолчанию (0 — без ограничения)
+	maxExpressionTimeout time.Duration
+	// allowInfinity разрешает бесконечные промежуточные и итоговые результаты вместо ошибки вычисления
+	allowInfinity = false
 	// expressionMutex мьютекс для синхронизации доступа к хранилищу математических выражений
 	expressionMutex = &sync.Mutex{}
 	// taskMutex мьютекс для синхронизации доступа к хранилищу задач
 	taskMutex = &sync.Mutex{}
-	// resultMutex мьютекс для синхронизации доступа к хранилищу математических выражений
-	resultMutex = &sync.Mutex{}
+	// waiterMutex мьютекс для синхронизации доступа к каналам ожидающих результата задач
+	waiterMutex = &sync.Mutex{}
 )
 
+// SetOperationTimes задает время выполнения математических операций
+func SetOperationTimes(times map[string]int) {
+	operationTimes = times
+}
+
+// SetTaskQueue задает очередь задач (и тем самым политику их выдачи агентам);
+// задачи, находившиеся в прежней очереди, в новую не переносятся
+func SetTaskQueue(q queue.Queue) {
+	taskMutex.Lock()
+	defer taskMutex.Unlock()
+	tasks = newRoutedQueue(q)
+}
+
+// SetMaxExpressionTimeout задает наибольшее время вычисления выражения; оно же применяется к выражениям,
+// для которых время не указано. 0 — без ограничения
+func SetMaxExpressionTimeout(timeout time.Duration) {
+	maxExpressionTimeout = timeout
+}
+
+// SetAllowInfinity задает политику для бесконечных результатов: при allow деление на ноль
+// и переполнение дают ±Inf (в JSON — строки "+Inf" и "-Inf"), иначе выражение завершается ошибкой
+func SetAllowInfinity(allow bool) {
+	allowInfinity = allow
+}
+
 // HandleCalculate обработчик http-запроса, принимает математическое выражение, возвращает ID
 func HandleCalculate(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
@@ -43,29 +83,84 @@ func HandleCalculate(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var req struct {
-		Expression string `json:"expression"`
+		Expression string             `json:"expression"`
+		Variables  map[string]float64 `json:"variables"`
+		Priority   int                `json:"priority"`
+		TimeoutMS  int                `json:"timeout_ms"`
+		Replicas   int                `json:"replicas"`
 	}
+	// размер тела ограничен: длина выражения определяет глубину рекурсии при построении графа задач
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestBody)
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, "request body too large", http.StatusRequestEntityTooLarge) // 413
+			return
+		}
 		http.Error(w, "invalid data", http.StatusUnprocessableEntity) // 422
 		return
 	}
+	// время сравнивается в миллисекундах до перевода в time.Duration, который переполнился бы
+	// при слишком большом значении
+	limitMS := int64(math.MaxInt64 / time.Millisecond)
+	if maxExpressionTimeout > 0 {
+		limitMS = maxExpressionTimeout.Milliseconds()
+	}
+	if req.TimeoutMS < 0 || int64(req.TimeoutMS) > limitMS {
+		http.Error(w, "invalid timeout_ms", http.StatusUnprocessableEntity) // 422
+		return
+	}
+	if req.Replicas < 0 || req.Replicas > maxReplicas {
+		http.Error(w, "invalid replicas", http.StatusUnprocessableEntity) // 422
+		return
+	}
+	for name := range req.Variables {
+		if !service.IsIdentifier(name) {
+			http.Error(w, fmt.Sprintf("invalid variable name %q", name), http.StatusUnprocessableEntity) // 422
+			return
+		}
+	}
+
+	// выражение проверяется до постановки в очередь, ошибка возвращается с указанием позиции
+	node, err := service.ValidateExpression(req.Expression, req.Variables)
+	if err != nil {
+		writeParseError(w, req.Expression, err)
+		return
+	}
+
+	// без указанного времени применяется наибольшее, заданное конфигурацией
+	timeout := time.Duration(req.TimeoutMS) * time.Millisecond
+	if timeout == 0 {
+		timeout = maxExpressionTimeout
+	}
 
 	expressionMutex.Lock()
 	expressionID++
 	id := strconv.Itoa(expressionID)
-	expressions[id] = models.Expression{
-		ID:     id,
-		Expr:   req.Expression,
-		Status: models.StatusExpressionPending,
-		Result: 0,
+	expr := models.NewExpression(id, req.Expression, time.Now())
+	expr.Variables = req.Variables
+	expr.Priority = req.Priority
+	expr.TimeoutMS = int(timeout.Milliseconds())
+	expr.Replicas = max(req.Replicas, 1)
+	expressions[id] = expr
+	evaluating[id] = true
+	var (
+		ctx    context.Context
+		cancel context.CancelFunc
+	)
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(context.Background(), timeout)
+	} else {
+		ctx, cancel = context.WithCancel(context.Background())
 	}
+	cancels[id] = cancel
 	expressionMutex.Unlock()
 
 	// Разбор математического выражения на задачи
-	go parseExpressionToTasks(id, req.Expression)
+	go parseExpressionToTasks(ctx, id, node, req.Variables, expr.Priority, expr.Replicas)
 
 	w.WriteHeader(http.StatusCreated) // 201
-	err := json.NewEncoder(w).Encode(map[string]string{"id": id})
+	err = json.NewEncoder(w).Encode(map[string]string{"id": id})
 	if err != nil {
 		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError) // 500
 		return
@@ -120,101 +215,303 @@ func HandleGetExpressionByID(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// HandleTask обработчик http-запроса, отдает задачу агенту или принимает результат вычисления задачи от агента
+// HandleTask обработчик http-запроса, отдает задачи агенту
+// (GET /internal/task[?agent_id=ID][&operations=OP,...][&wait_ms=N][&max=N])
+// или принимает результаты вычисления задач от агента
 func HandleTask(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	default:
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed) // 405
 	case http.MethodGet:
-		taskMutex.Lock()
-		defer taskMutex.Unlock()
+		// параметр wait_ms включает длинный опрос: при пустой очереди ответ откладывается
+		// до появления задачи, но не дольше указанного времени
+		var wait time.Duration
+		if waitMS := r.URL.Query().Get("wait_ms"); waitMS != "" {
+			ms, err := strconv.Atoi(waitMS)
+			if err != nil || ms < 0 {
+				http.Error(w, "invalid wait_ms", http.StatusBadRequest) // 400
+				return
+			}
+			wait = min(time.Duration(ms)*time.Millisecond, maxTaskWait)
+		}
+
+		// параметр max запрашивает пакет из не более чем max задач, ответ содержит список "tasks"
+		batch := r.URL.Query().Has("max")
+		limit := 1
+		if batch {
+			n, err := strconv.Atoi(r.URL.Query().Get("max"))
+			if err != nil || n < 1 {
+				http.Error(w, "invalid max", http.StatusBadRequest) // 400
+				return
+			}
+			limit = min(n, maxTaskBatch)
+		}
 
-		for _, task := range tasks {
-			_ = json.NewEncoder(w).Encode(map[string]models.Task{"task": task})
-			delete(tasks, task.ID)
+		// результат задачи принимается только от агента, указанного при ее получении
+		agent := r.URL.Query().Get("agent_id")
+		if isQuarantined(agent) {
+			http.Error(w, "agent is quarantined", http.StatusForbidden) // 403
+			return
+		}
+		// параметр operations перечисляет операции и встроенные функции, которые поддерживает агент;
+		// без параметра агент получает задачи с любыми операциями
+		operations := parseOperations(r.URL.Query().Get("operations"), r.URL.Query().Has("operations"))
+		fetched := awaitTasks(r.Context(), agent, operations, wait, limit)
+		if len(fetched) == 0 {
+			http.Error(w, "no tasks", http.StatusNotFound) // 404
+			return
+		}
+		if batch {
+			_ = json.NewEncoder(w).Encode(map[string][]models.Task{"tasks": fetched})
 			return
 		}
-		http.Error(w, "no tasks", http.StatusNotFound) // 404
+		_ = json.NewEncoder(w).Encode(map[string]models.Task{"task": fetched[0]})
 
 	case http.MethodPost:
-		var result models.TaskResult
-		if err := json.NewDecoder(r.Body).Decode(&result); err != nil {
+		// тело запроса содержит один результат или пакет результатов в поле "results"
+		var submission struct {
+			models.TaskResult
+			Results []models.TaskResult `json:"results"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&submission); err != nil {
 			http.Error(w, http.StatusText(http.StatusUnprocessableEntity), http.StatusUnprocessableEntity) // 422
 			return
 		}
 
-		// сохраняем результат вычисления математического выражения в хранилище результатов задач
-		resultMutex.Lock()
-		results[result.ID] = result.Result
-		resultMutex.Unlock()
+		if submission.Results == nil {
+			if status := submitResult(submission.TaskResult); status != http.StatusOK {
+				http.Error(w, resultErrors[status], status)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+			return
+		}
 
-		w.WriteHeader(http.StatusOK)
+		// результаты пакета обрабатываются независимо, ответ содержит код обработки каждого из них
+		statuses := make([]models.TaskResultStatus, 0, len(submission.Results))
+		for _, result := range submission.Results {
+			statuses = append(statuses, models.TaskResultStatus{ID: result.ID, Status: submitResult(result)})
+		}
+		w.WriteHeader(http.StatusOK) // 200
+		_ = json.NewEncoder(w).Encode(map[string][]models.TaskResultStatus{"results": statuses})
 	}
 }
 
-// parseExpressionToTasks рабирает математическое выражение на задачи
-func parseExpressionToTasks(id, expr string) {
-	expr = strings.ReplaceAll(expr, " ", "")
+// resultErrors описания причин, по которым результат задачи не принят
+var resultErrors = map[int]string{
+	http.StatusForbidden: "task is not leased by agent",
+	http.StatusNotFound:  "task not found",
+	http.StatusConflict:  "task already has a different result",
+}
 
-	// разделение выражения на токены
-	tokens := service.TokenizeExpression(expr)
+// submitResult принимает результат задачи от агента, арендующего задачу, и передает его ожидающему
+// вычислению выражения; результаты реплик задачи учитываются голосованием (см. vote). Повторная отправка
+// принятого результата (например, после потери ответа) не меняет результат выражения, отличающийся
+// результат выполненной задачи отклоняется; результат задачи, снятой с выполнения, принимается,
+// но не учитывается. Результаты агентов в карантине не принимаются. Ошибка, которая может не повториться,
+// не передается выражению: задача выполняется повторно. Возвращает http-код обработки результата
+func submitResult(result models.TaskResult) int {
+	taskMutex.Lock()
+	defer taskMutex.Unlock()
 
-	// преобразование токенов в обратную польскую запись (Reverse Polish Notation (RPN))
-	rpnTokens := service.ShuntingYard(tokens)
+	if _, untrusted := quarantined[result.AgentID]; untrusted {
+		return http.StatusForbidden // 403
+	}
+	if previous, voted := votes[result.ID][result.AgentID]; voted {
+		if !sameResult(previous, result) {
+			return http.StatusConflict // 409
+		}
+		return http.StatusOK // 200
+	}
 
-	// вычисление RPN и создание задач
-	var stack []string
-	for _, token := range rpnTokens {
-		if service.IsNumber(token) {
-			stack = append(stack, token)
-		} else if service.IsOperator(token) {
-			if len(stack) < 2 {
-				log.Println("error: not enough operands for operator", token)
-				return
-			}
+	// задача, возвращенная в очередь после истечения аренды или неудачной попытки, не арендована;
+	// реплика выполненной задачи остается арендованной до получения результата
+	if retired, exists := retiredTasks[result.ID]; exists {
+		return submitRetired(retired, result)
+	}
 
-			arg2 := stack[len(stack)-1]
-			arg1 := stack[len(stack)-2]
-			stack = stack[:len(stack)-2]
-
-			// создание задачи
-			taskID++
-			task := models.Task{
-				ID:            strconv.Itoa(taskID),
-				Arg1:          service.ParseNumber(arg1),
-				Arg2:          service.ParseNumber(arg2),
-				Operation:     token,
-				OperationTime: operationTimes[token],
-			}
+	l := leases[result.ID]
+	_, holder := l.holders[result.AgentID]
+	_, completed := completedTasks[result.ID]
+	if completed && !holder {
+		accepted, answered := acceptedResults[result.ID]
+		if answered && !sameResult(accepted, result) {
+			return http.StatusConflict // 409
+		}
+		return http.StatusOK // 200
+	}
 
-			// сохранение задачи в хранилище задач
-			taskMutex.Lock()
-			tasks[task.ID] = task
-			taskMutex.Unlock()
-
-			// ожидание результата вычисления задачи
-			for {
-				resultMutex.Lock()
-				result, exists := results[task.ID]
-				resultMutex.Unlock()
-
-				if exists {
-					stack = append(stack, fmt.Sprintf("%f", result))
-					break
-				}
-				time.Sleep(100 * time.Millisecond)
-			}
+	waiterMutex.Lock()
+	_, exists := waiters[result.ID]
+	waiterMutex.Unlock()
+	if !exists && !completed {
+		return http.StatusNotFound // 404
+	}
+	if !holder {
+		return http.StatusForbidden // 403
+	}
+
+	switch {
+	case result.Error != "" && result.Retryable && completed:
+		releaseLease(result.ID, result.AgentID)
+	case result.Error != "" && result.Retryable:
+		failTask(l.task, result.AgentID, result.Error, time.Now())
+	case replicas(l.task) > 1:
+		countCompleted(result.AgentID)
+		vote(l.task, result, time.Now())
+	default:
+		countCompleted(result.AgentID)
+		releaseLease(result.ID, result.AgentID)
+		acceptResult(result)
+	}
+	return http.StatusOK // 200
+}
+
+// acceptResult отмечает задачу выполненной и передает принятый результат ожидающему его вычислению
+// выражения. Вызывается при заблокированном taskMutex
+func acceptResult(result models.TaskResult) {
+	waiterMutex.Lock()
+	waiter, exists := waiters[result.ID]
+	delete(waiters, result.ID)
+	waiterMutex.Unlock()
+
+	completeTask(result)
+	// в буфере канала есть место: результат задачи передается только один раз
+	if exists {
+		waiter <- result
+	}
+}
+
+// parseExpressionToTasks вычисляет дерево разбора математического выражения, передавая операции агентам:
+// все задачи, операнды которых уже вычислены, публикуются одновременно и выполняются разными агентами
+// параллельно; промежуточные результаты передаются между задачами как float64 без округления.
+// Выражение проходит статусы "parsing" (построение графа задач) и "running" (выполнение задач)
+func parseExpressionToTasks(ctx context.Context, id string, node ast.Node, variables map[string]float64,
+	priority, replicas int) {
+	var (
+		graph  *service.Graph
+		result float64
+		err    = ctx.Err()
+	)
+	// выражение, отмененное до начала вычисления, не переходит к построению графа и публикации задач
+	if err == nil && transitionExpression(id, models.StatusExpressionParsing, "") {
+		graph, err = service.BuildGraph(node, variables)
+	}
+	// выражение, отмененное или не вычисленное в срок за время построения графа, не переходит
+	// к публикации задач и завершается с причиной отмены
+	if err == nil {
+		err = ctx.Err()
+	}
+	if err == nil && transitionExpression(id, models.StatusExpressionRunning, "") {
+		result, err = graph.Execute(func(task models.Task) (float64, error) {
+			task.ExpressionID = id
+			task.Priority = priority
+			task.Replicas = replicas
+			return computeTask(ctx, task)
+		})
+	}
+
+	// задачи, ожидающие результата после ошибки в другой ветви выражения, снимаются с выполнения
+	expressionMutex.Lock()
+	cancel, exists := cancels[id]
+	delete(cancels, id)
+	delete(evaluating, id)
+	expressionMutex.Unlock()
+	if exists {
+		cancel()
+	}
+	taskMutex.Lock()
+	retireExpression(id)
+	taskMutex.Unlock()
+
+	// сохранение результата вычисления математического выражения или причины ошибки
+	expressionMutex.Lock()
+	defer expressionMutex.Unlock()
+
+	expr := expressions[id]
+	switch {
+	case errors.Is(err, context.DeadlineExceeded) ||
+		expr.Status == models.StatusExpressionBlocked && errors.Is(ctx.Err(), context.DeadlineExceeded):
+		// задачи выражения уже сняты с выполнения
+		err = expr.Transition(models.StatusExpressionTimedOut,
+			fmt.Sprintf("expression was not evaluated within %d ms", expr.TimeoutMS), time.Now())
+	case expr.Status == models.StatusExpressionBlocked:
+		// вычисление остановлено задачей из очереди недоставленных задач: причина ошибки задачи сохраняется
+		err = expr.Transition(models.StatusExpressionFailed, expr.Reason, time.Now())
+	case err != nil:
+		err = expr.Transition(models.StatusExpressionFailed, errorReason(expr.Expr, err), time.Now())
+	default:
+		expr.Result = models.Float(result)
+		err = expr.Transition(models.StatusExpressionCompleted, "", time.Now())
+	}
+	// результат отмененного выражения не сохраняется: переход из статуса "cancelled" недопустим;
+	// любой другой отклоненный переход оставил бы выражение незавершенным
+	if err != nil {
+		if expr.Status != models.StatusExpressionCancelled {
+			log.Printf("expression %s is left in status %q: %v", id, expr.Status, err)
 		}
+		return
 	}
+	expressions[id] = expr
+}
+
+// transitionExpression переводит выражение в статус to, если переход допустим
+// (например, отмененное выражение не переходит к выполнению задач); возвращает, выполнен ли переход
+func transitionExpression(id, to, reason string) bool {
+	expressionMutex.Lock()
+	defer expressionMutex.Unlock()
 
-	// сохранение результата вычисления математического выражения
-	if len(stack) == 1 {
-		result, _ := strconv.ParseFloat(stack[0], 64)
-		expressionMutex.Lock()
-		expr := expressions[id]
-		expr.Status = models.StatusExpressionCompleted
-		expr.Result = result
-		expressions[id] = expr
-		expressionMutex.Unlock()
+	expr := expressions[id]
+	if err := expr.Transition(to, reason, time.Now()); err != nil {
+		return false
+	}
+	expressions[id] = expr
+	return true
+}
+
+// computeTask назначает задаче ID и время выполнения, передает ее агентам и ожидает результат вычисления.
+// Результат передается через канал задачи сразу после его получения от агента.
+// Ошибка агента возвращается как ошибка задачи, кроме бесконечного результата при разрешенных бесконечностях.
+// При отмене ctx задача снимается с выполнения, а ее результат, если он придет позже, отбрасывается
+func computeTask(ctx context.Context, task models.Task) (float64, error) {
+	// канал создается до публикации задачи, чтобы результат не мог прийти раньше ожидающего;
+	// буфер на один результат не блокирует обработчик HandleTask
+	waiter := make(chan models.TaskResult, 1)
+
+	taskMutex.Lock()
+	// задача отмененного выражения не публикуется: иначе агент мог бы получить ее до снятия с выполнения
+	if ctx.Err() != nil {
+		taskMutex.Unlock()
+		return 0, ctx.Err()
+	}
+	taskID++
+	task.ID = strconv.Itoa(taskID)
+	task.OperationTime = operationTimes[task.Operation]
+	trackTask(task)
+
+	waiterMutex.Lock()
+	waiters[task.ID] = waiter
+	waiterMutex.Unlock()
+
+	// сохранение задачи в хранилище задач
+	pushTask(task)
+	taskMutex.Unlock()
+
+	// ожидание результата вычисления задачи или отмены вычисления выражения
+	var result models.TaskResult
+	select {
+	case result = <-waiter:
+	case <-ctx.Done():
+		taskMutex.Lock()
+		// задачи выражения, вычисление которого уже завершено, сняты с выполнения в retireExpression
+		if isTracked(task) {
+			discardTask(task.ID)
+		}
+		taskMutex.Unlock()
+		return 0, ctx.Err()
+	}
+	if result.Error != "" && !(allowInfinity && math.IsInf(float64(result.Result), 0)) {
+		return 0, errors.New(result.Error)
 	}
+	return float64(result.Result), nil
 }
@@ -0,0 +1,213 @@
+package orchestrator
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/ivanov-nikolay/distributed_calculator/internal/models"
+)
+
+// lease аренда задачи агентами: задача, выданная агенту, не отдается другим агентам до истечения срока,
+// кроме задач с несколькими репликами, каждая из которых выдается отдельному агенту
+type lease struct {
+	// task выданная задача
+	task models.Task
+	// holders сроки аренды по ID агентов, получивших задачу; результат задачи принимается только от них.
+	// По истечении срока задача возвращается в очередь
+	holders map[string]time.Time
+}
+
+var (
+	// leases задачи, выданные агентам и ожидающие результата; доступ синхронизируется taskMutex
+	leases = make(map[string]lease)
+	// completedTasks ID задач, результат которых уже получен или которые сняты с выполнения;
+	// доступ синхронизируется taskMutex
+	completedTasks = make(map[string]struct{})
+	// acceptedResults принятые результаты выполненных задач; доступ синхронизируется taskMutex
+	acceptedResults = make(map[string]models.TaskResult)
+	// expressionTasks ID задач выражений, вычисление которых еще не завершено; доступ синхронизируется taskMutex
+	expressionTasks = make(map[string][]string)
+	// retiredTasks результаты задач завершенных выражений, по которым отвечают на запоздавшие повторные отправки;
+	// хранятся не более retiredLimit последних задач в порядке retiredOrder. Доступ синхронизируется taskMutex
+	retiredTasks = make(map[string]retiredTask)
+	retiredOrder []string
+	retiredLimit = 10000
+	// leaseTimeout время, которое дается агенту на вычисление задачи сверх времени выполнения операции
+	leaseTimeout = 30 * time.Second
+)
+
+// SetLeaseTimeout задает время аренды задачи сверх времени выполнения ее операции
+func SetLeaseTimeout(timeout time.Duration) {
+	leaseTimeout = timeout
+}
+
+// leaseTask запоминает агента и срок аренды задачи, извлеченной из очереди для выдачи агенту.
+// Вызывается при заблокированном taskMutex
+func leaseTask(task models.Task, agent string, now time.Time) {
+	l, exists := leases[task.ID]
+	if !exists {
+		l = lease{task: task, holders: make(map[string]time.Time)}
+		leases[task.ID] = l
+	}
+	l.holders[agent] = now.Add(time.Duration(task.OperationTime)*time.Millisecond + leaseTimeout)
+}
+
+// releaseLease снимает аренду задачи id агентом agent. Вызывается при заблокированном taskMutex
+func releaseLease(id, agent string) {
+	l, exists := leases[id]
+	if !exists {
+		return
+	}
+	delete(l.holders, agent)
+	if len(l.holders) == 0 {
+		delete(leases, id)
+	}
+}
+
+// completeTask отмечает задачу выполненной, запоминает принятый результат и убирает задачу из очереди,
+// куда она могла быть возвращена для выдачи очередной реплики. Вызывается при заблокированном taskMutex
+func completeTask(result models.TaskResult) {
+	completedTasks[result.ID] = struct{}{}
+	acceptedResults[result.ID] = result
+	tasks.Remove(result.ID)
+	delete(failedAttempts, result.ID)
+}
+
+// retiredTask результаты задачи завершенного выражения
+type retiredTask struct {
+	// accepted принятый результат задачи; answered — был ли он получен до снятия задачи с выполнения
+	accepted models.TaskResult
+	answered bool
+	// votes результаты реплик задачи по ID агентов
+	votes map[string]models.TaskResult
+	// holders агенты, которым выдана реплика выполненной задачи и от которых еще ожидается результат
+	holders map[string]time.Time
+}
+
+// submitRetired принимает запоздавший результат задачи завершенного выражения: повторная отправка
+// принятого результата или результата реплики принимается, отличающийся результат отклоняется,
+// а результат ожидаемой реплики учитывается, как в vote. Возвращает http-код обработки результата.
+// Вызывается при заблокированном taskMutex
+func submitRetired(r retiredTask, result models.TaskResult) int {
+	if previous, voted := r.votes[result.AgentID]; voted {
+		if !sameResult(previous, result) {
+			return http.StatusConflict // 409
+		}
+		return http.StatusOK // 200
+	}
+
+	if _, holder := r.holders[result.AgentID]; holder && r.answered {
+		delete(r.holders, result.AgentID)
+		if result.Error != "" && result.Retryable {
+			return http.StatusOK // 200
+		}
+		countCompleted(result.AgentID)
+		if r.votes == nil {
+			r.votes = make(map[string]models.TaskResult)
+			retiredTasks[result.ID] = r
+		}
+		r.votes[result.AgentID] = result
+		if !sameValue(r.accepted, result) {
+			quarantineAgent(result.AgentID, result.ID, time.Now())
+		}
+		return http.StatusOK // 200
+	}
+
+	if r.answered && !sameResult(r.accepted, result) {
+		return http.StatusConflict // 409
+	}
+	return http.StatusOK // 200
+}
+
+// trackTask запоминает задачу выражения, вычисление которого еще не завершено.
+// Вызывается при заблокированном taskMutex
+func trackTask(task models.Task) {
+	expressionTasks[task.ExpressionID] = append(expressionTasks[task.ExpressionID], task.ID)
+}
+
+// isTracked проверяет, не завершено ли вычисление выражения задачи. Вызывается при заблокированном taskMutex
+func isTracked(task models.Task) bool {
+	_, tracked := expressionTasks[task.ExpressionID]
+	return tracked
+}
+
+// retireExpression удаляет состояние задач выражения, вычисление которого завершено, в том числе
+// из очереди недоставленных задач, оставляя только результаты для ответа на запоздавшие повторные отправки.
+// Вызывается при заблокированном taskMutex
+func retireExpression(id string) {
+	for _, taskID := range expressionTasks[id] {
+		accepted, answered := acceptedResults[taskID]
+		retiredTasks[taskID] = retiredTask{
+			accepted: accepted,
+			answered: answered,
+			votes:    votes[taskID],
+			holders:  leases[taskID].holders,
+		}
+		retiredOrder = append(retiredOrder, taskID)
+
+		tasks.Remove(taskID)
+		delete(completedTasks, taskID)
+		delete(acceptedResults, taskID)
+		delete(votes, taskID)
+		delete(disputes, taskID)
+		delete(leases, taskID)
+		delete(failedAttempts, taskID)
+		delete(deadLetters, taskID)
+
+		waiterMutex.Lock()
+		delete(waiters, taskID)
+		waiterMutex.Unlock()
+	}
+	delete(expressionTasks, id)
+
+	for len(retiredOrder) > retiredLimit {
+		delete(retiredTasks, retiredOrder[0])
+		retiredOrder = retiredOrder[1:]
+	}
+}
+
+// ReapExpiredTasks учитывает как неудачную попытку каждую задачу, срок аренды которой истек к моменту now
+// (например, агент аварийно завершился во время вычисления): задача возвращается в очередь или, после
+// исчерпания попыток, в очередь недоставленных задач; возвращает количество таких задач
+func ReapExpiredTasks(now time.Time) int {
+	taskMutex.Lock()
+	defer taskMutex.Unlock()
+
+	count := 0
+	for id, l := range leases {
+		for agent, deadline := range l.holders {
+			if !now.After(deadline) {
+				continue
+			}
+			// реплика уже выполненной задачи не выдается повторно
+			if _, completed := completedTasks[id]; completed {
+				releaseLease(id, agent)
+			} else {
+				failTask(l.task, agent, "lease expired", now)
+			}
+			count++
+		}
+	}
+	return count
+}
+
+// RunTaskReaper с периодом interval возвращает в очередь задачи с истекшей арендой и задачи агентов,
+// переставших отправлять сигналы активности, задачи, которые не может вычислить ни один агент,
+// перемещает в очередь недоставленных задач и завершает вычисление выражений, слишком долго ожидающих
+// задачу из этой очереди, пока не закрыт stop
+func RunTaskReaper(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			ReapExpiredTasks(now)
+			ReapLostAgents(now)
+			ReapUnroutableTasks(now)
+			ReapDeadLetters(now)
+		}
+	}
+}
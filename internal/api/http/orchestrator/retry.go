@@ -0,0 +1,207 @@
+package orchestrator
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ivanov-nikolay/distributed_calculator/internal/models"
+)
+
+var (
+	// failedAttempts количество неудачных попыток выполнения задач; доступ синхронизируется taskMutex
+	failedAttempts = make(map[string]int)
+	// deadLetters задачи, не выполненные за допустимое число попыток; доступ синхронизируется taskMutex
+	deadLetters = make(map[string]models.DeadLetter)
+	// maxAttempts допустимое число попыток выполнения задачи
+	maxAttempts = 3
+	// retryBackoff задержка перед повторной попыткой; удваивается с каждой неудачной попыткой
+	retryBackoff = time.Second
+	// deadLetterHold время, в течение которого выражение ожидает возврата задачи из очереди
+	// недоставленных задач; по его истечении вычисление выражения завершается ошибкой
+	// (0 — вычисление завершается сразу)
+	deadLetterHold time.Duration
+)
+
+// SetRetryPolicy задает допустимое число попыток выполнения задачи и начальную задержку перед повторной попыткой
+func SetRetryPolicy(attempts int, backoff time.Duration) {
+	maxAttempts = attempts
+	retryBackoff = backoff
+}
+
+// SetDeadLetterHold задает время, в течение которого выражение ожидает возврата задачи из очереди
+// недоставленных задач (0 — вычисление завершается сразу)
+func SetDeadLetterHold(hold time.Duration) {
+	deadLetterHold = hold
+}
+
+// failTask учитывает неудачную попытку выполнения задачи агентом agent: снимает аренду и возвращает задачу
+// в очередь после задержки, а после исчерпания попыток перемещает ее в очередь недоставленных задач и
+// останавливает вычисление выражения. Вызывается при заблокированном taskMutex
+func failTask(task models.Task, agent, reason string, now time.Time) {
+	releaseLease(task.ID, agent)
+	failedAttempts[task.ID]++
+	attempts := failedAttempts[task.ID]
+
+	if attempts >= maxAttempts {
+		deadLetters[task.ID] = models.DeadLetter{Task: task, Attempts: attempts, Reason: reason, FailedAt: now}
+		blockExpression(task.ExpressionID, fmt.Sprintf("task %s failed after %d attempts: %s", task.ID, attempts, reason))
+		return
+	}
+
+	delay := retryBackoff << (attempts - 1)
+	if delay <= 0 {
+		pushTask(task)
+		return
+	}
+	time.AfterFunc(delay, func() {
+		taskMutex.Lock()
+		defer taskMutex.Unlock()
+
+		// за время задержки мог прийти запоздавший результат задачи или завершиться вычисление выражения
+		if _, done := completedTasks[task.ID]; !done && isTracked(task) {
+			pushTask(task)
+		}
+	})
+}
+
+// blockExpression переводит в статус "blocked" выражение, вычисление которого остановлено задачей
+// из очереди недоставленных задач; вычисление продолжится, если задача будет возвращена в очередь
+// до истечения deadLetterHold, а без времени ожидания сразу завершается ошибкой задачи
+func blockExpression(id, reason string) {
+	expressionMutex.Lock()
+	defer expressionMutex.Unlock()
+
+	if !evaluating[id] {
+		return
+	}
+	expr := expressions[id]
+	if err := expr.Transition(models.StatusExpressionBlocked, reason, time.Now()); err != nil {
+		return
+	}
+	expressions[id] = expr
+	if deadLetterHold <= 0 {
+		// вычисление завершается в parseExpressionToTasks, которое сохраняет причину ошибки задачи
+		delete(evaluating, id)
+		cancels[id]()
+	}
+}
+
+// restoreExpression возвращает выражение в статус "running", если у него не осталось задач
+// в очереди недоставленных задач. Вызывается при заблокированном taskMutex
+func restoreExpression(id string) {
+	for _, letter := range deadLetters {
+		if letter.Task.ExpressionID == id {
+			return
+		}
+	}
+
+	expressionMutex.Lock()
+	defer expressionMutex.Unlock()
+
+	if !evaluating[id] {
+		return
+	}
+	expr := expressions[id]
+	if err := expr.Transition(models.StatusExpressionRunning, "", time.Now()); err == nil {
+		expressions[id] = expr
+	}
+}
+
+// ReapDeadLetters завершает вычисление выражений, задачи которых находятся в очереди недоставленных задач
+// дольше deadLetterHold к моменту now: выражение переходит в статус "failed" с причиной ошибки задачи,
+// а его задачи удаляются из очереди недоставленных задач; возвращает количество таких выражений
+func ReapDeadLetters(now time.Time) int {
+	taskMutex.Lock()
+	defer taskMutex.Unlock()
+
+	expired := make(map[string]bool)
+	for _, letter := range deadLetters {
+		if now.Sub(letter.FailedAt) >= deadLetterHold {
+			expired[letter.Task.ExpressionID] = true
+		}
+	}
+
+	expressionMutex.Lock()
+	defer expressionMutex.Unlock()
+
+	count := 0
+	for id := range expired {
+		if !evaluating[id] {
+			continue
+		}
+		// вычисление завершается в parseExpressionToTasks, которое снимает с выполнения остальные задачи
+		delete(evaluating, id)
+		cancels[id]()
+		count++
+	}
+	return count
+}
+
+// HandleGetDeadLetters обработчик http-запроса, возвращает задачи из очереди недоставленных задач
+func HandleGetDeadLetters(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed) // 405
+		return
+	}
+
+	taskMutex.Lock()
+	letters := make([]models.DeadLetter, 0, len(deadLetters))
+	for _, letter := range deadLetters {
+		letters = append(letters, letter)
+	}
+	taskMutex.Unlock()
+
+	sort.Slice(letters, func(i, j int) bool {
+		return letters[i].FailedAt.Before(letters[j].FailedAt)
+	})
+
+	w.WriteHeader(http.StatusOK) // 200
+	err := json.NewEncoder(w).Encode(map[string][]models.DeadLetter{"dead_letters": letters})
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError) // 500
+		return
+	}
+}
+
+// HandleRequeueDeadLetter обработчик http-запроса POST /internal/dead-letters/{id}/requeue,
+// возвращает задачу из очереди недоставленных задач в очередь задач с новым набором попыток
+func HandleRequeueDeadLetter(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed) // 405
+		return
+	}
+
+	path := r.URL.Path[len("/internal/dead-letters/"):]
+	id, found := strings.CutSuffix(path, "/requeue")
+	if !found {
+		http.Error(w, "not found", http.StatusNotFound) // 404
+		return
+	}
+
+	taskMutex.Lock()
+	defer taskMutex.Unlock()
+
+	letter, exists := deadLetters[id]
+	if !exists {
+		http.Error(w, "dead letter not found", http.StatusNotFound) // 404
+		return
+	}
+	// задача выражения, вычисление которого завершается, удаляется из очереди при его завершении
+	expressionMutex.Lock()
+	resumable := evaluating[letter.Task.ExpressionID]
+	expressionMutex.Unlock()
+	if !resumable {
+		http.Error(w, "expression is no longer evaluated", http.StatusConflict) // 409
+		return
+	}
+	delete(deadLetters, id)
+	delete(failedAttempts, id)
+	pushTask(letter.Task)
+	restoreExpression(letter.Task.ExpressionID)
+
+	w.WriteHeader(http.StatusOK) // 200
+}
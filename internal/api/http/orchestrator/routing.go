@@ -0,0 +1,232 @@
+package orchestrator
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ivanov-nikolay/distributed_calculator/internal/models"
+	"github.com/ivanov-nikolay/distributed_calculator/internal/queue"
+)
+
+// advertisement операции, которые поддерживает агент, по его последнему запросу задач
+type advertisement struct {
+	// operations операции агента; nil — все операции
+	operations map[string]bool
+	// seen время последнего запроса задач
+	seen time.Time
+	// polling количество запросов задач агента, ожидающих ответа
+	polling int
+}
+
+// routedQueue очередь задач, учитывающая задачи в очереди по операциям, которые они требуют от агента
+type routedQueue struct {
+	queue.Queue
+	// capabilities задачи в очереди по операциям и ID задач
+	capabilities map[string]map[string]models.Task
+	// queued операции задач в очереди по ID задач
+	queued map[string]string
+}
+
+var (
+	// advertised операции, которые поддерживают агенты, по ID агентов; доступ синхронизируется taskMutex
+	advertised = make(map[string]advertisement)
+	// unroutable время, с которого задачи с операцией ожидают в очереди, а ни один активный агент
+	// не поддерживает эту операцию, по операциям; доступ синхронизируется taskMutex
+	unroutable = make(map[string]time.Time)
+	// routingTimeout время, в течение которого задача может ожидать агента, поддерживающего ее операцию
+	routingTimeout = time.Minute
+)
+
+// SetRoutingTimeout задает время, в течение которого задача может ожидать агента, поддерживающего ее операцию
+func SetRoutingTimeout(timeout time.Duration) {
+	routingTimeout = timeout
+}
+
+// newRoutedQueue создает очередь задач, учитывающую задачи в очереди q по операциям
+func newRoutedQueue(q queue.Queue) *routedQueue {
+	return &routedQueue{
+		Queue:        q,
+		capabilities: make(map[string]map[string]models.Task),
+		queued:       make(map[string]string),
+	}
+}
+
+// Push добавляет задачу в очередь
+func (q *routedQueue) Push(task models.Task) {
+	if _, exists := q.queued[task.ID]; exists {
+		return
+	}
+	q.Queue.Push(task)
+	capability := task.Capability()
+	if q.capabilities[capability] == nil {
+		q.capabilities[capability] = make(map[string]models.Task)
+	}
+	q.capabilities[capability][task.ID] = task
+	q.queued[task.ID] = capability
+}
+
+// Pop извлекает следующую задачу согласно политике очереди
+func (q *routedQueue) Pop() (models.Task, bool) {
+	task, exists := q.Queue.Pop()
+	if exists {
+		q.forget(task.ID)
+	}
+	return task, exists
+}
+
+// PopFirst извлекает первую в порядке выдачи задачу, для которой match возвращает true
+func (q *routedQueue) PopFirst(match func(models.Task) bool) (models.Task, bool) {
+	task, exists := q.Queue.PopFirst(match)
+	if exists {
+		q.forget(task.ID)
+	}
+	return task, exists
+}
+
+// Remove удаляет задачу из очереди
+func (q *routedQueue) Remove(id string) bool {
+	if !q.Queue.Remove(id) {
+		return false
+	}
+	q.forget(id)
+	return true
+}
+
+// offers проверяет, есть ли в очереди задачи, операции которых поддерживает агент с операциями operations
+func (q *routedQueue) offers(operations map[string]bool) bool {
+	if operations == nil {
+		return q.Len() > 0
+	}
+	for operation := range operations {
+		if _, queued := q.capabilities[operation]; queued {
+			return true
+		}
+	}
+	return false
+}
+
+// forget перестает учитывать задачу, извлеченную из очереди
+func (q *routedQueue) forget(id string) {
+	capability := q.queued[id]
+	delete(q.queued, id)
+	delete(q.capabilities[capability], id)
+	if len(q.capabilities[capability]) == 0 {
+		delete(q.capabilities, capability)
+	}
+}
+
+// parseOperations разбирает список операций, которые поддерживает агент, из параметра запроса
+// operations (через запятую); nil означает, что агент поддерживает все операции
+func parseOperations(param string, exists bool) map[string]bool {
+	if !exists {
+		return nil
+	}
+	operations := make(map[string]bool)
+	for _, operation := range strings.Split(param, ",") {
+		if operation = strings.TrimSpace(operation); operation != "" {
+			operations[operation] = true
+		}
+	}
+	return operations
+}
+
+// supports проверяет, поддерживает ли агент с операциями operations операцию capability
+func supports(operations map[string]bool, capability string) bool {
+	return operations == nil || operations[capability]
+}
+
+// canCompute проверяет, поддерживает ли агент с операциями operations операцию задачи
+func canCompute(task models.Task, operations map[string]bool) bool {
+	return supports(operations, task.Capability())
+}
+
+// startPolling запоминает операции, которые поддерживает агент, в начале его запроса задач.
+// Вызывается при заблокированном taskMutex
+func startPolling(agent string, operations map[string]bool, now time.Time) {
+	a := advertised[agent]
+	a.operations = operations
+	a.seen = now
+	a.polling++
+	advertised[agent] = a
+}
+
+// stopPolling отмечает завершение запроса задач агента. Вызывается при заблокированном taskMutex
+func stopPolling(agent string, now time.Time) {
+	a := advertised[agent]
+	a.seen = now
+	a.polling--
+	advertised[agent] = a
+}
+
+// isActive проверяет, активен ли к моменту now агент: ожидает задачу, недавно запрашивал задачи
+// или отправлял сигнал активности. Вызывается при заблокированном taskMutex
+func isActive(agent string, a advertisement, now time.Time) bool {
+	if a.polling > 0 || now.Sub(a.seen) <= heartbeatTimeout {
+		return true
+	}
+	registered, exists := agents[agent]
+	return exists && now.Sub(registered.LastHeartbeat) <= heartbeatTimeout
+}
+
+// isRoutable проверяет, может ли задача с операцией capability дождаться агента к моменту now:
+// операцию поддерживает хотя бы один активный агент, или активных агентов нет вовсе и судить
+// о поддерживаемых операциях не по чему. Вызывается при заблокированном taskMutex
+func isRoutable(capability string, now time.Time) bool {
+	active := false
+	for agent, a := range advertised {
+		if !isActive(agent, a, now) {
+			continue
+		}
+		if supports(a.operations, capability) {
+			return true
+		}
+		active = true
+	}
+	return !active
+}
+
+// ReapUnroutableTasks перемещает в очередь недоставленных задач задачи, операцию которых к моменту now
+// дольше допустимого времени не поддерживает ни один активный агент (агенты, поддерживающие операцию,
+// но занятые другими задачами, не мешают задаче дождаться их), и отмечает выражения ошибкой с описанием
+// причины; возвращает количество таких задач
+func ReapUnroutableTasks(now time.Time) int {
+	taskMutex.Lock()
+	defer taskMutex.Unlock()
+
+	for agent, a := range advertised {
+		if !isActive(agent, a, now) {
+			delete(advertised, agent)
+		}
+	}
+	for capability := range unroutable {
+		if _, queued := tasks.capabilities[capability]; !queued {
+			delete(unroutable, capability)
+		}
+	}
+
+	count := 0
+	for capability, queued := range tasks.capabilities {
+		if isRoutable(capability, now) {
+			delete(unroutable, capability)
+			continue
+		}
+		since, exists := unroutable[capability]
+		if !exists {
+			unroutable[capability] = now
+		}
+		if !exists || now.Sub(since) <= routingTimeout {
+			continue
+		}
+		delete(unroutable, capability)
+
+		reason := fmt.Sprintf("no agent supports operation %q for %d ms", capability, routingTimeout.Milliseconds())
+		for id, task := range queued {
+			tasks.Remove(id)
+			deadLetters[id] = models.DeadLetter{Task: task, Attempts: failedAttempts[id], Reason: reason, FailedAt: now}
+			blockExpression(task.ExpressionID, fmt.Sprintf("task %s: %s", id, reason))
+			count++
+		}
+	}
+	return count
+}
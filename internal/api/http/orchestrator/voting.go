@@ -0,0 +1,183 @@
+package orchestrator
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/ivanov-nikolay/distributed_calculator/internal/models"
+)
+
+var (
+	// votes результаты реплик задач по ID агентов; доступ синхронизируется taskMutex
+	votes = make(map[string]map[string]models.TaskResult)
+	// disputes количество дополнительных реплик задач, результаты реплик которых не достигли кворума;
+	// доступ синхронизируется taskMutex
+	disputes = make(map[string]int)
+	// quarantined агенты, результаты которых разошлись с принятыми по кворуму: они не получают задач,
+	// а их результаты не принимаются; доступ синхронизируется taskMutex
+	quarantined = make(map[string]models.QuarantinedAgent)
+	// maxReplicas наибольшее количество реплик задачи, которое можно запросить для выражения
+	maxReplicas = 5
+)
+
+// SetMaxReplicas задает наибольшее количество реплик задачи, которое можно запросить для выражения
+func SetMaxReplicas(limit int) {
+	maxReplicas = limit
+}
+
+// replicas количество разных агентов, которым выдается задача
+func replicas(task models.Task) int {
+	return max(task.Replicas, 1)
+}
+
+// quorum количество совпадающих результатов реплик, достаточное для принятия результата задачи
+func quorum(task models.Task) int {
+	return replicas(task)/2 + 1
+}
+
+// missingReplicas количество реплик задачи, которые еще нужно выдать агентам.
+// Вызывается при заблокированном taskMutex
+func missingReplicas(task models.Task) int {
+	return replicas(task) + disputes[task.ID] - len(votes[task.ID]) - len(leases[task.ID].holders)
+}
+
+// canTake проверяет, может ли агент получить задачу: реплики задачи выдаются разным агентам.
+// Вызывается при заблокированном taskMutex
+func canTake(task models.Task, agent string) bool {
+	_, holder := leases[task.ID].holders[agent]
+	_, voted := votes[task.ID][agent]
+	return !holder && !voted
+}
+
+// sameValue проверяет, совпадают ли значения результатов задачи, в том числе равные NaN
+func sameValue(a, b models.TaskResult) bool {
+	return a.Error == b.Error && math.Float64bits(float64(a.Result)) == math.Float64bits(float64(b.Result))
+}
+
+// sameResult проверяет, является ли результат b повторной отправкой результата a
+func sameResult(a, b models.TaskResult) bool {
+	return a.AgentID == b.AgentID && a.Retryable == b.Retryable && sameValue(a, b)
+}
+
+// vote учитывает результат реплики задачи: результат, с которым совпали результаты кворума реплик,
+// передается ожидающему его вычислению выражения, а агенты, результаты которых с ним разошлись (в том числе
+// запоздавшие), помещаются в карантин. Если все реплики выполнены, а кворум не достигнут, это учитывается
+// как неудачная попытка, и задача выдается еще одному агенту. Вызывается при заблокированном taskMutex
+func vote(task models.Task, result models.TaskResult, now time.Time) {
+	releaseLease(task.ID, result.AgentID)
+	if votes[task.ID] == nil {
+		votes[task.ID] = make(map[string]models.TaskResult)
+	}
+	votes[task.ID][result.AgentID] = result
+
+	if accepted, completed := acceptedResults[task.ID]; completed {
+		if !sameValue(accepted, result) {
+			quarantineAgent(result.AgentID, task.ID, now)
+		}
+		return
+	}
+
+	for _, candidate := range votes[task.ID] {
+		agreed := 0
+		for _, other := range votes[task.ID] {
+			if sameValue(candidate, other) {
+				agreed++
+			}
+		}
+		if agreed < quorum(task) {
+			continue
+		}
+
+		acceptResult(candidate)
+		for agent, other := range votes[task.ID] {
+			if !sameValue(candidate, other) {
+				quarantineAgent(agent, task.ID, now)
+			}
+		}
+		return
+	}
+
+	if len(votes[task.ID]) >= replicas(task)+disputes[task.ID] {
+		disputes[task.ID]++
+		failTask(task, result.AgentID, "replica results did not reach quorum", now)
+	}
+}
+
+// quarantineAgent помещает агента в карантин, отмечая задачу, результат которой разошелся с принятым;
+// выданные агенту задачи возвращаются в очередь. Вызывается при заблокированном taskMutex
+func quarantineAgent(agent, taskID string, now time.Time) {
+	record, exists := quarantined[agent]
+	if !exists {
+		record = models.QuarantinedAgent{AgentID: agent, QuarantinedAt: now}
+	}
+	record.Tasks = append(record.Tasks, taskID)
+	quarantined[agent] = record
+
+	for id, l := range leases {
+		if _, holder := l.holders[agent]; !holder {
+			continue
+		}
+		releaseLease(id, agent)
+		if _, completed := completedTasks[id]; !completed {
+			pushTask(l.task)
+		}
+	}
+}
+
+// isQuarantined проверяет, находится ли агент в карантине
+func isQuarantined(agent string) bool {
+	taskMutex.Lock()
+	defer taskMutex.Unlock()
+
+	_, exists := quarantined[agent]
+	return exists
+}
+
+// HandleGetQuarantine обработчик http-запроса, возвращает агентов, находящихся в карантине
+func HandleGetQuarantine(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed) // 405
+		return
+	}
+
+	taskMutex.Lock()
+	agents := make([]models.QuarantinedAgent, 0, len(quarantined))
+	for _, agent := range quarantined {
+		agents = append(agents, agent)
+	}
+	taskMutex.Unlock()
+
+	sort.Slice(agents, func(i, j int) bool {
+		return agents[i].QuarantinedAt.Before(agents[j].QuarantinedAt)
+	})
+
+	w.WriteHeader(http.StatusOK) // 200
+	err := json.NewEncoder(w).Encode(map[string][]models.QuarantinedAgent{"agents": agents})
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError) // 500
+		return
+	}
+}
+
+// HandleReleaseAgent обработчик http-запроса DELETE /internal/quarantine/{id}, выводит агента из карантина
+func HandleReleaseAgent(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed) // 405
+		return
+	}
+
+	agent := r.URL.Path[len("/internal/quarantine/"):]
+	taskMutex.Lock()
+	defer taskMutex.Unlock()
+
+	if _, exists := quarantined[agent]; !exists {
+		http.Error(w, "agent is not quarantined", http.StatusNotFound) // 404
+		return
+	}
+	delete(quarantined, agent)
+
+	w.WriteHeader(http.StatusOK) // 200
+}
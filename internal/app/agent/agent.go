@@ -2,47 +2,147 @@ package agent
 
 import (
 	"log"
-	"sync"
+	"os"
 	"time"
 
 	"github.com/ivanov-nikolay/distributed_calculator/internal/config"
+	"github.com/ivanov-nikolay/distributed_calculator/internal/models"
 	"github.com/ivanov-nikolay/distributed_calculator/internal/transport/agent"
 	"github.com/ivanov-nikolay/distributed_calculator/pkg/calculator"
 )
 
+const (
+	// fetchTaskWait время, в течение которого оркестратор может отложить ответ на запрос задачи
+	fetchTaskWait = 30 * time.Second
+	// version версия агента, сообщаемая оркестратору при регистрации
+	version = "1.0.0"
+)
+
 // ApplicationAgent содержит конфигурацию агента
 type ApplicationAgent struct {
 	config *config.Agent
 }
 
-// NewApplicationAgent создает новый объект ApplicationAgent
-func NewApplicationAgent() *ApplicationAgent {
-	return &ApplicationAgent{
-		config: config.LoadConfigAgent(),
+// NewApplicationAgent создает новый объект ApplicationAgent; возвращает ошибку загрузки конфигурации
+func NewApplicationAgent() (*ApplicationAgent, error) {
+	cfg, err := config.LoadConfigAgent()
+	if err != nil {
+		return nil, err
 	}
+	return &ApplicationAgent{
+		config: cfg,
+	}, nil
 }
 
-// RunApplicationAgent запускает агента
+// RunApplicationAgent запускает агента: задачи запрашиваются пакетами по числу свободных вычислителей
+// и распределяются между ними, а результаты отправляются оркестратору пакетами
 func (a *ApplicationAgent) RunApplicationAgent() {
-	var wg sync.WaitGroup
-	for i := 0; i < a.config.ComputingPower; i++ {
-		wg.Add(1)
+	power := a.config.ComputingPower
+	tasks := make(chan models.Task)
+	results := make(chan models.TaskResult, power)
+
+	// idle содержит по одному элементу на каждый свободный вычислитель
+	idle := make(chan struct{}, power)
+	for i := 0; i < power; i++ {
+		idle <- struct{}{}
 		go func() {
-			defer wg.Done()
-			for {
-				task, err := agent.FetchTask()
-				if err != nil {
-					log.Println("error fetching task:", err)
-					time.Sleep(1 * time.Second)
-					continue
-				}
-
-				result := calculator.ComputeTask(task)
-				if err := agent.SendResult(task.ID, result); err != nil {
-					log.Println("error sending result:", err)
-				}
+			for task := range tasks {
+				result := computeResult(task)
+				result.AgentID = a.config.AgentID
+				results <- result
+				idle <- struct{}{}
 			}
 		}()
 	}
-	wg.Wait()
+	go sendResults(results, power)
+	go a.sendHeartbeats()
+
+	for {
+		// ожидаем хотя бы одного свободного вычислителя и занимаем всех свободных
+		<-idle
+		free := 1
+		for free < power && len(idle) > 0 {
+			<-idle
+			free++
+		}
+
+		batch, err := agent.FetchTasks(a.config.AgentID, calculator.Capabilities(), free, fetchTaskWait)
+		if err != nil {
+			log.Println("error fetching tasks:", err)
+			time.Sleep(1 * time.Second)
+		}
+		for _, task := range batch {
+			tasks <- task
+		}
+		for i := len(batch); i < free; i++ {
+			idle <- struct{}{}
+		}
+	}
+}
+
+// register регистрирует агента у оркестратора
+func (a *ApplicationAgent) register() error {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return err
+	}
+	return agent.Register(models.Agent{
+		ID:             a.config.AgentID,
+		Hostname:       hostname,
+		Version:        version,
+		ComputingPower: a.config.ComputingPower,
+	})
+}
+
+// sendHeartbeats регистрирует агента и периодически отправляет оркестратору сигналы активности;
+// если оркестратор не знает агента (например, после перезапуска), агент регистрируется заново
+func (a *ApplicationAgent) sendHeartbeats() {
+	registered := false
+	ticker := time.NewTicker(time.Duration(a.config.HeartbeatIntervalMS) * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		if registered {
+			alive, err := agent.Heartbeat(a.config.AgentID)
+			if err != nil {
+				log.Println("error sending heartbeat:", err)
+			}
+			// при ошибке связи сигнал отправляется повторно без регистрации
+			registered = alive || err != nil
+		}
+		if !registered {
+			if err := a.register(); err != nil {
+				log.Println("error registering agent:", err)
+			} else {
+				log.Printf("agent %s registered", a.config.AgentID)
+				registered = true
+			}
+		}
+		<-ticker.C
+	}
+}
+
+// computeResult вычисляет задачу и формирует ее результат
+func computeResult(task models.Task) models.TaskResult {
+	value, err := calculator.ComputeTask(task)
+	result := models.TaskResult{ID: task.ID, Result: models.Float(value)}
+	if err != nil {
+		result.Error = err.Error()
+		result.Retryable = !calculator.IsDeterministic(err)
+	}
+	return result
+}
+
+// sendResults отправляет результаты оркестратору: к первому полученному результату добавляются
+// все уже готовые результаты, но не более max в одном пакете
+func sendResults(results <-chan models.TaskResult, max int) {
+	for result := range results {
+		batch := []models.TaskResult{result}
+		for len(batch) < max && len(results) > 0 {
+			batch = append(batch, <-results)
+		}
+		if err := agent.SendResults(batch); err != nil {
+			log.Println("error sending results:", err)
+		}
+	}
 }
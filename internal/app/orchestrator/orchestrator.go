@@ -3,37 +3,80 @@ package orchestrator
 import (
 	"log"
 	"net/http"
+	"time"
 
 	"github.com/ivanov-nikolay/distributed_calculator/internal/api/http/orchestrator"
 	"github.com/ivanov-nikolay/distributed_calculator/internal/config"
+	"github.com/ivanov-nikolay/distributed_calculator/internal/models"
+	"github.com/ivanov-nikolay/distributed_calculator/internal/queue"
 )
 
-// operationTimes хранилище времени выполнения математических операций
-var operationTimes = map[string]int{}
+// taskReaperInterval период проверки аренды задач
+const taskReaperInterval = time.Second
 
 // ApplicationOrchestrator содержит конфигурацию оркестратора
 type ApplicationOrchestrator struct {
 	orchestrator *config.Orchestrator
 }
 
-// NewApplicationOrchestrator создает новый экземпляр ApplicationOrchestrator
-func NewApplicationOrchestrator() *ApplicationOrchestrator {
-	return &ApplicationOrchestrator{
-		orchestrator: config.LoadConfigOrchestrator(),
+// NewApplicationOrchestrator создает новый экземпляр ApplicationOrchestrator; возвращает ошибку
+// загрузки конфигурации
+func NewApplicationOrchestrator() (*ApplicationOrchestrator, error) {
+	cfg, err := config.LoadConfigOrchestrator()
+	if err != nil {
+		return nil, err
 	}
+	return &ApplicationOrchestrator{
+		orchestrator: cfg,
+	}, nil
 }
 
 // RunApplicationOrchestrator запускает оркестратор
 func (a *ApplicationOrchestrator) RunApplicationOrchestrator() {
-	operationTimes["+"] = a.orchestrator.TimeAdditionMS
-	operationTimes["-"] = a.orchestrator.TimeSubtractionMS
-	operationTimes["*"] = a.orchestrator.TimeMultiplicationsMS
-	operationTimes["/"] = a.orchestrator.TimeDivisionsMS
+	orchestrator.SetOperationTimes(map[string]int{
+		"+":   a.orchestrator.TimeAdditionMS,
+		"-":   a.orchestrator.TimeSubtractionMS,
+		"*":   a.orchestrator.TimeMultiplicationsMS,
+		"/":   a.orchestrator.TimeDivisionsMS,
+		"^":   a.orchestrator.TimePowerMS,
+		"%":   a.orchestrator.TimeModuloMS,
+		"//":  a.orchestrator.TimeIntDivisionMS,
+		"neg": a.orchestrator.TimeSubtractionMS,
+
+		models.OperationCall: a.orchestrator.TimeFunctionMS,
+	})
+	orchestrator.SetAllowInfinity(a.orchestrator.AllowInfinity)
+	orchestrator.SetMaxExpressionTimeout(time.Duration(a.orchestrator.ExpressionMaxTimeoutMS) * time.Millisecond)
+
+	// задачи одного приоритета выдаются согласно политике TASK_QUEUE_POLICY, проверенной при загрузке конфигурации
+	aging := time.Duration(a.orchestrator.TaskPriorityAgingMS) * time.Millisecond
+	orchestrator.SetTaskQueue(queue.NewPriority(aging, time.Now, func() queue.Queue {
+		level, _ := queue.New(a.orchestrator.TaskQueuePolicy)
+		return level
+	}))
+	orchestrator.SetLeaseTimeout(time.Duration(a.orchestrator.TaskLeaseTimeoutMS) * time.Millisecond)
+	orchestrator.SetRetryPolicy(a.orchestrator.TaskMaxAttempts, time.Duration(a.orchestrator.TaskRetryBackoffMS)*time.Millisecond)
+	orchestrator.SetHeartbeatTimeout(time.Duration(a.orchestrator.HeartbeatTimeoutMS) * time.Millisecond)
+	orchestrator.SetRoutingTimeout(time.Duration(a.orchestrator.TaskRoutingTimeoutMS) * time.Millisecond)
+	orchestrator.SetDeadLetterHold(time.Duration(a.orchestrator.DeadLetterHoldMS) * time.Millisecond)
+	orchestrator.SetMaxReplicas(a.orchestrator.TaskMaxReplicas)
+
+	// задачи, выданные агентам и не вычисленные в срок или выданные недоступным агентам, возвращаются в очередь,
+	// задачи, которые не может вычислить ни один агент, перемещаются в очередь недоставленных задач,
+	// а выражения, слишком долго ожидающие задачу из этой очереди, завершаются ошибкой
+	go orchestrator.RunTaskReaper(taskReaperInterval, make(chan struct{}))
 
 	http.HandleFunc("/api/v1/calculate", orchestrator.HandleCalculate)
 	http.HandleFunc("/api/v1/expressions", orchestrator.HandleGetExpressions)
-	http.HandleFunc("/api/v1/expressions/", orchestrator.HandleGetExpressionByID)
+	http.HandleFunc("/api/v1/expressions/", orchestrator.HandleExpressionByID)
 	http.HandleFunc("/internal/task", orchestrator.HandleTask)
+	http.HandleFunc("/internal/dead-letters", orchestrator.HandleGetDeadLetters)
+	http.HandleFunc("/internal/dead-letters/", orchestrator.HandleRequeueDeadLetter)
+	http.HandleFunc("/internal/quarantine", orchestrator.HandleGetQuarantine)
+	http.HandleFunc("/internal/quarantine/", orchestrator.HandleReleaseAgent)
+	http.HandleFunc("/internal/agents", orchestrator.HandleAgents)
+	http.HandleFunc("/internal/agents/", orchestrator.HandleAgentHeartbeat)
+	http.HandleFunc("/admin/agents", orchestrator.HandleAgentsPage)
 
 	log.Println("orchestrator is running on :8080")
 	log.Fatal(http.ListenAndServe(a.orchestrator.ServerPort, nil))
@@ -0,0 +1,117 @@
+package ast
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Node узел дерева разбора математического выражения
+type Node interface {
+	// Pos смещение начала узла от начала выражения в байтах
+	Pos() int
+	// String запись узла с полной расстановкой скобок
+	String() string
+}
+
+// Number числовой литерал
+type Number struct {
+	// Value значение литерала
+	Value float64
+	// Position смещение литерала (или его знака) от начала выражения
+	Position int
+}
+
+// Variable именованная константа или переменная
+type Variable struct {
+	// Name имя переменной
+	Name string
+	// Position смещение имени от начала выражения
+	Position int
+}
+
+// Unary унарная операция
+type Unary struct {
+	// Operator унарный оператор ("-")
+	Operator string
+	// Operand операнд
+	Operand Node
+	// Position смещение оператора от начала выражения
+	Position int
+}
+
+// Binary бинарная операция
+type Binary struct {
+	// Operator бинарный оператор ("+", "-", "*", "/", "%", "//", "^")
+	Operator string
+	// Left левый операнд
+	Left Node
+	// Right правый операнд
+	Right Node
+	// Position смещение оператора от начала выражения
+	Position int
+}
+
+// Call вызов встроенной функции
+type Call struct {
+	// Function имя функции
+	Function string
+	// Args аргументы вызова
+	Args []Node
+	// Position смещение имени функции от начала выражения
+	Position int
+}
+
+// Pos возвращает смещение литерала
+func (n *Number) Pos() int { return n.Position }
+
+// Pos возвращает смещение имени переменной
+func (n *Variable) Pos() int { return n.Position }
+
+// Pos возвращает смещение унарного оператора
+func (n *Unary) Pos() int { return n.Position }
+
+// Pos возвращает смещение бинарного оператора
+func (n *Binary) Pos() int { return n.Position }
+
+// Pos возвращает смещение имени функции
+func (n *Call) Pos() int { return n.Position }
+
+func (n *Number) String() string {
+	return strconv.FormatFloat(n.Value, 'g', -1, 64)
+}
+
+func (n *Variable) String() string {
+	return n.Name
+}
+
+func (n *Unary) String() string {
+	return "(" + n.Operator + n.Operand.String() + ")"
+}
+
+func (n *Binary) String() string {
+	return "(" + n.Left.String() + " " + n.Operator + " " + n.Right.String() + ")"
+}
+
+func (n *Call) String() string {
+	args := make([]string, 0, len(n.Args))
+	for _, arg := range n.Args {
+		args = append(args, arg.String())
+	}
+	return n.Function + "(" + strings.Join(args, ", ") + ")"
+}
+
+// Walk обходит дерево в прямом порядке, вызывая visit для каждого узла
+func Walk(node Node, visit func(Node)) {
+	visit(node)
+	switch n := node.(type) {
+	case *Unary:
+		Walk(n.Operand, visit)
+	case *Binary:
+		Walk(n.Left, visit)
+		Walk(n.Right, visit)
+	case *Call:
+		for _, arg := range n.Args {
+			Walk(arg, visit)
+		}
+	}
+}
@@ -1,25 +1,49 @@
 package config
 
 import (
+	"fmt"
 	"log"
+	"math"
 	"os"
 	"strconv"
+	"time"
 
+	"github.com/ivanov-nikolay/distributed_calculator/internal/queue"
 	"github.com/joho/godotenv"
 )
 
+// maxDurationMS наибольшее время в миллисекундах, представимое time.Duration
+const maxDurationMS = int64(math.MaxInt64 / time.Millisecond)
+
 // Orchestrator структура, содержащая конфигурационные параметры оркестратора
 type Orchestrator struct {
-	ServerPort            string
-	TimeAdditionMS        int
-	TimeSubtractionMS     int
-	TimeMultiplicationsMS int
-	TimeDivisionsMS       int
+	ServerPort             string
+	TimeAdditionMS         int
+	TimeSubtractionMS      int
+	TimeMultiplicationsMS  int
+	TimeDivisionsMS        int
+	TimePowerMS            int
+	TimeModuloMS           int
+	TimeIntDivisionMS      int
+	TimeFunctionMS         int
+	AllowInfinity          bool
+	TaskLeaseTimeoutMS     int
+	TaskMaxAttempts        int
+	TaskRetryBackoffMS     int
+	TaskQueuePolicy        string
+	TaskPriorityAgingMS    int
+	ExpressionMaxTimeoutMS int
+	HeartbeatTimeoutMS     int
+	TaskRoutingTimeoutMS   int
+	DeadLetterHoldMS       int
+	TaskMaxReplicas        int
 }
 
 // Agent структура, содержащая конфигурационные параметры агента
 type Agent struct {
-	ComputingPower int
+	ComputingPower      int
+	AgentID             string
+	HeartbeatIntervalMS int
 }
 
 // ServerPort конфигурация севера
@@ -27,12 +51,12 @@ type ServerPort struct {
 	ServerPort string
 }
 
-// LoadConfigOrchestrator загружает параметры для запуска сервера
-func LoadConfigOrchestrator() *Orchestrator {
+// LoadConfigOrchestrator загружает параметры для запуска сервера; значение вне допустимого диапазона
+// возвращается ошибкой
+func LoadConfigOrchestrator() (*Orchestrator, error) {
 	err := godotenv.Load(".env")
 	if err != nil {
-		log.Fatalf("error loading .env file: %v", err)
-		return nil
+		return nil, fmt.Errorf("error loading .env file: %w", err)
 	}
 
 	port, exists := os.LookupEnv("SERVER_PORT")
@@ -55,38 +79,174 @@ func LoadConfigOrchestrator() *Orchestrator {
 	if !exists {
 		timeDivisionsMS = "2000"
 	}
+	timePowerMS, exists := os.LookupEnv("TIME_POWER_MS")
+	if !exists {
+		timePowerMS = "2000"
+	}
+	timeModuloMS, exists := os.LookupEnv("TIME_MODULO_MS")
+	if !exists {
+		timeModuloMS = "2000"
+	}
+	timeIntDivisionMS, exists := os.LookupEnv("TIME_INT_DIVISION_MS")
+	if !exists {
+		timeIntDivisionMS = "2000"
+	}
+	timeFunctionMS, exists := os.LookupEnv("TIME_FUNCTION_MS")
+	if !exists {
+		timeFunctionMS = "2000"
+	}
+	allowInfinityEnv, exists := os.LookupEnv("ALLOW_INFINITY")
+	if !exists {
+		allowInfinityEnv = "false"
+	}
+	taskLeaseTimeoutMS, exists := os.LookupEnv("TASK_LEASE_TIMEOUT_MS")
+	if !exists {
+		taskLeaseTimeoutMS = "30000"
+	}
+	taskMaxAttemptsEnv, exists := os.LookupEnv("TASK_MAX_ATTEMPTS")
+	if !exists {
+		taskMaxAttemptsEnv = "3"
+	}
+	taskRetryBackoffMS, exists := os.LookupEnv("TASK_RETRY_BACKOFF_MS")
+	if !exists {
+		taskRetryBackoffMS = "1000"
+	}
+	taskQueuePolicy, exists := os.LookupEnv("TASK_QUEUE_POLICY")
+	if !exists {
+		taskQueuePolicy = "fifo"
+	}
+	taskPriorityAgingMS, exists := os.LookupEnv("TASK_PRIORITY_AGING_MS")
+	if !exists {
+		taskPriorityAgingMS = "10000"
+	}
+	expressionMaxTimeoutMS, exists := os.LookupEnv("EXPRESSION_MAX_TIMEOUT_MS")
+	if !exists {
+		expressionMaxTimeoutMS = "600000"
+	}
+	agentHeartbeatTimeoutMS, exists := os.LookupEnv("AGENT_HEARTBEAT_TIMEOUT_MS")
+	if !exists {
+		agentHeartbeatTimeoutMS = "15000"
+	}
+	taskRoutingTimeoutMS, exists := os.LookupEnv("TASK_ROUTING_TIMEOUT_MS")
+	if !exists {
+		taskRoutingTimeoutMS = "60000"
+	}
+	deadLetterHoldMS, exists := os.LookupEnv("DEAD_LETTER_HOLD_MS")
+	if !exists {
+		deadLetterHoldMS = "0"
+	}
+	taskMaxReplicasEnv, exists := os.LookupEnv("TASK_MAX_REPLICAS")
+	if !exists {
+		taskMaxReplicasEnv = "5"
+	}
 
-	timeAddition, err := strconv.ParseInt(timeAdditionMS, 10, 64)
+	timeAddition, err := parseInt("TIME_ADDITION_MS", timeAdditionMS, 0, maxDurationMS)
+	if err != nil {
+		return nil, err
+	}
+	timeSubtraction, err := parseInt("TIME_SUBTRACTION_MS", timeSubtructionMS, 0, maxDurationMS)
+	if err != nil {
+		return nil, err
+	}
+	timeMultiplications, err := parseInt("TIME_MULTIPLIER_MS", timeMultiplicationsMS, 0, maxDurationMS)
+	if err != nil {
+		return nil, err
+	}
+	timeDivisions, err := parseInt("TIME_DIVISION_MS", timeDivisionsMS, 0, maxDurationMS)
 	if err != nil {
-		log.Fatalf("error parsing TIME_ADDITION_MS: %v", err)
+		return nil, err
 	}
-	timeSubtraction, err := strconv.ParseInt(timeSubtructionMS, 10, 64)
+	timePower, err := parseInt("TIME_POWER_MS", timePowerMS, 0, maxDurationMS)
 	if err != nil {
-		log.Fatalf("error parsing TIME_SUBTRACTION_MS: %v", err)
+		return nil, err
 	}
-	timeMultiplications, err := strconv.ParseInt(timeMultiplicationsMS, 10, 64)
+	timeModulo, err := parseInt("TIME_MODULO_MS", timeModuloMS, 0, maxDurationMS)
 	if err != nil {
-		log.Fatalf("error parsing TIME_MULTIPLIER_MS: %v", err)
+		return nil, err
 	}
-	timeDivisions, err := strconv.ParseInt(timeDivisionsMS, 10, 64)
+	timeIntDivision, err := parseInt("TIME_INT_DIVISION_MS", timeIntDivisionMS, 0, maxDurationMS)
 	if err != nil {
-		log.Fatalf("error parsing TIME_DIVISION_MS: %v", err)
+		return nil, err
+	}
+	timeFunction, err := parseInt("TIME_FUNCTION_MS", timeFunctionMS, 0, maxDurationMS)
+	if err != nil {
+		return nil, err
+	}
+	allowInfinity, err := strconv.ParseBool(allowInfinityEnv)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing ALLOW_INFINITY: %w", err)
+	}
+	taskLeaseTimeout, err := parseInt("TASK_LEASE_TIMEOUT_MS", taskLeaseTimeoutMS, 1, maxDurationMS)
+	if err != nil {
+		return nil, err
+	}
+	taskMaxAttempts, err := parseInt("TASK_MAX_ATTEMPTS", taskMaxAttemptsEnv, 1, math.MaxInt32)
+	if err != nil {
+		return nil, err
+	}
+	taskRetryBackoff, err := parseInt("TASK_RETRY_BACKOFF_MS", taskRetryBackoffMS, 0, maxDurationMS)
+	if err != nil {
+		return nil, err
+	}
+	// задачи одного приоритета выдаются согласно политике TASK_QUEUE_POLICY
+	if _, err := queue.New(taskQueuePolicy); err != nil {
+		return nil, fmt.Errorf("error parsing TASK_QUEUE_POLICY: %w", err)
+	}
+	taskPriorityAging, err := parseInt("TASK_PRIORITY_AGING_MS", taskPriorityAgingMS, 0, maxDurationMS)
+	if err != nil {
+		return nil, err
+	}
+	expressionMaxTimeout, err := parseInt("EXPRESSION_MAX_TIMEOUT_MS", expressionMaxTimeoutMS, 0, maxDurationMS)
+	if err != nil {
+		return nil, err
+	}
+	agentHeartbeatTimeout, err := parseInt("AGENT_HEARTBEAT_TIMEOUT_MS", agentHeartbeatTimeoutMS, 1, maxDurationMS)
+	if err != nil {
+		return nil, err
+	}
+	taskRoutingTimeout, err := parseInt("TASK_ROUTING_TIMEOUT_MS", taskRoutingTimeoutMS, 1, maxDurationMS)
+	if err != nil {
+		return nil, err
+	}
+	deadLetterHold, err := parseInt("DEAD_LETTER_HOLD_MS", deadLetterHoldMS, 0, maxDurationMS)
+	if err != nil {
+		return nil, err
+	}
+	taskMaxReplicas, err := parseInt("TASK_MAX_REPLICAS", taskMaxReplicasEnv, 1, math.MaxInt32)
+	if err != nil {
+		return nil, err
 	}
 
 	return &Orchestrator{
-		ServerPort:            port,
-		TimeAdditionMS:        int(timeAddition),
-		TimeSubtractionMS:     int(timeSubtraction),
-		TimeMultiplicationsMS: int(timeMultiplications),
-		TimeDivisionsMS:       int(timeDivisions),
-	}
+		ServerPort:             port,
+		TimeAdditionMS:         timeAddition,
+		TimeSubtractionMS:      timeSubtraction,
+		TimeMultiplicationsMS:  timeMultiplications,
+		TimeDivisionsMS:        timeDivisions,
+		TimePowerMS:            timePower,
+		TimeModuloMS:           timeModulo,
+		TimeIntDivisionMS:      timeIntDivision,
+		TimeFunctionMS:         timeFunction,
+		AllowInfinity:          allowInfinity,
+		TaskLeaseTimeoutMS:     taskLeaseTimeout,
+		TaskMaxAttempts:        taskMaxAttempts,
+		TaskRetryBackoffMS:     taskRetryBackoff,
+		TaskQueuePolicy:        taskQueuePolicy,
+		TaskPriorityAgingMS:    taskPriorityAging,
+		ExpressionMaxTimeoutMS: expressionMaxTimeout,
+		HeartbeatTimeoutMS:     agentHeartbeatTimeout,
+		TaskRoutingTimeoutMS:   taskRoutingTimeout,
+		DeadLetterHoldMS:       deadLetterHold,
+		TaskMaxReplicas:        taskMaxReplicas,
+	}, nil
 }
 
-// LoadConfigAgent загружает параметры для запуска сервера
-func LoadConfigAgent() *Agent {
+// LoadConfigAgent загружает параметры для запуска агента; значение вне допустимого диапазона
+// возвращается ошибкой
+func LoadConfigAgent() (*Agent, error) {
 	err := godotenv.Load(".env")
 	if err != nil {
-		log.Fatalf("error loading .env file: %v", err)
+		return nil, fmt.Errorf("error loading .env file: %w", err)
 	}
 
 	computingPower, exists := os.LookupEnv("COMPUTING_POWER")
@@ -94,14 +254,48 @@ func LoadConfigAgent() *Agent {
 		computingPower = "4"
 	}
 
-	computingPowerInt, err := strconv.Atoi(computingPower)
+	computingPowerInt, err := parseInt("COMPUTING_POWER", computingPower, 1, math.MaxInt32)
+	if err != nil {
+		return nil, err
+	}
+
+	// ID агента должен быть уникальным среди агентов, поэтому по умолчанию составляется из имени хоста и PID
+	agentID, exists := os.LookupEnv("AGENT_ID")
+	if !exists {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return nil, fmt.Errorf("error getting hostname: %w", err)
+		}
+		agentID = fmt.Sprintf("%s-%d", hostname, os.Getpid())
+	}
+
+	heartbeatIntervalMS, exists := os.LookupEnv("AGENT_HEARTBEAT_INTERVAL_MS")
+	if !exists {
+		heartbeatIntervalMS = "5000"
+	}
+	// интервал используется как период time.Ticker, поэтому должен быть положительным
+	heartbeatInterval, err := parseInt("AGENT_HEARTBEAT_INTERVAL_MS", heartbeatIntervalMS, 1, maxDurationMS)
 	if err != nil {
-		log.Fatalf("error parsing COMPUTING_POWER: %v", err)
+		return nil, err
 	}
 
 	return &Agent{
-		ComputingPower: computingPowerInt,
+		ComputingPower:      computingPowerInt,
+		AgentID:             agentID,
+		HeartbeatIntervalMS: heartbeatInterval,
+	}, nil
+}
+
+// parseInt разбирает целое значение переменной окружения name и проверяет, что оно лежит в диапазоне [min, max]
+func parseInt(name, value string, min, max int64) (int, error) {
+	number, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("error parsing %s: %w", name, err)
+	}
+	if number < min || number > max {
+		return 0, fmt.Errorf("%s must be between %d and %d, got %d", name, min, max, number)
 	}
+	return int(number), nil
 }
 
 // LoadServerPort загружает конфигурацию сервера
@@ -1,7 +1,25 @@
 package models
 
-// Статус задачи
+// Статус математического выражения
 const (
-	StatusExpressionPending   = "pending"
+	// StatusExpressionQueued выражение проверено и принято, вычисление еще не начато
+	StatusExpressionQueued = "queued"
+	// StatusExpressionParsing строится граф задач выражения
+	StatusExpressionParsing = "parsing"
+	// StatusExpressionRunning задачи выражения выполняются агентами
+	StatusExpressionRunning = "running"
+	// StatusExpressionCompleted выражение вычислено
 	StatusExpressionCompleted = "completed"
+	// StatusExpressionBlocked вычисление остановлено задачей из очереди недоставленных задач
+	// и возобновится, если задача будет возвращена в очередь
+	StatusExpressionBlocked = "blocked"
+	// StatusExpressionFailed вычисление завершилось ошибкой
+	StatusExpressionFailed = "failed"
+	// StatusExpressionCancelled вычисление отменено клиентом
+	StatusExpressionCancelled = "cancelled"
+	// StatusExpressionTimedOut выражение не вычислено за отведенное время
+	StatusExpressionTimedOut = "timed_out"
 )
+
+// Операция задачи вызова встроенной функции
+const OperationCall = "call"
@@ -0,0 +1,50 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// Float вещественное число, которое кодируется в JSON числом, а бесконечности и NaN,
+// не представимые в JSON, — строками "+Inf", "-Inf" и "NaN"
+type Float float64
+
+// MarshalJSON кодирует число в JSON
+func (f Float) MarshalJSON() ([]byte, error) {
+	value := float64(f)
+	if math.IsInf(value, 0) || math.IsNaN(value) {
+		return json.Marshal(strconv.FormatFloat(value, 'g', -1, 64))
+	}
+	return json.Marshal(value)
+}
+
+// UnmarshalJSON декодирует число или строковую запись бесконечности и NaN
+func (f *Float) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		value, err := strconv.ParseFloat(s, 64)
+		if err != nil || !(math.IsInf(value, 0) || math.IsNaN(value)) {
+			return fmt.Errorf("invalid non-finite number %q", s)
+		}
+		*f = Float(value)
+		return nil
+	}
+
+	var value float64
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	*f = Float(value)
+	return nil
+}
+
+// Floats преобразует срез float64 в срез Float
+func Floats(values []float64) []Float {
+	result := make([]Float, 0, len(values))
+	for _, value := range values {
+		result = append(result, Float(value))
+	}
+	return result
+}
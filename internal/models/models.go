@@ -1,40 +1,150 @@
 package models
 
+import "time"
+
 // Expression описание математического выражения
 type Expression struct {
 	// ID выражения
 	ID string `json:"id"`
 	// Expr математическое выражение
 	Expr string `json:"expression"`
+	// Variables значения переменных, использованных в выражении
+	Variables map[string]float64 `json:"variables,omitempty"`
+	// Priority приоритет выражения: задачи выражений с большим приоритетом выдаются агентам раньше
+	Priority int `json:"priority"`
+	// TimeoutMS время в миллисекундах, за которое выражение должно быть вычислено (0 — без ограничения)
+	TimeoutMS int `json:"timeout_ms,omitempty"`
+	// Replicas количество разных агентов, которым выдается каждая задача выражения
+	Replicas int `json:"replicas"`
 	// Status текущее состояние вычисления математического выражения
 	Status string `json:"status"`
 	// Result результат вычисления математического выражения
-	Result float64 `json:"result"`
+	Result Float `json:"result"`
+	// Reason причина неуспешного завершения (статусы "failed" и "timed_out") или остановки вычисления (статус "blocked")
+	Reason string `json:"reason,omitempty"`
+	// Transitions история переходов выражения между статусами, начиная с "queued"
+	Transitions []Transition `json:"transitions"`
 }
 
 // Task описание задачи для агента
 type Task struct {
 	// ID задачи
 	ID string `json:"id"`
+	// ExpressionID ID выражения, для вычисления которого создана задача
+	ExpressionID string `json:"expression_id,omitempty"`
+	// Priority приоритет выражения, для вычисления которого создана задача
+	Priority int `json:"priority,omitempty"`
+	// Replicas количество разных агентов, которым выдается задача; агентам не передается
+	Replicas int `json:"-"`
 	// Arg1 первый аргумент
-	Arg1 float64 `json:"arg1"`
-	// Arg2 второй аргумент
-	Arg2 float64 `json:"arg2"`
-	// Operator математическое действие ("+", "-", "*", "/")
+	Arg1 Float `json:"arg1"`
+	// Arg2 второй аргумент (не используется унарными операциями)
+	Arg2 Float `json:"arg2"`
+	// Operator математическое действие ("+", "-", "*", "/", "%", "//", "^", "neg", "call")
 	Operation string `json:"operation"`
+	// Function имя встроенной функции (только для операции "call")
+	Function string `json:"function,omitempty"`
+	// Args аргументы встроенной функции (только для операции "call")
+	Args []Float `json:"args,omitempty"`
 	// OperationTime время выполнения операции в миллисекундах
 	OperationTime int `json:"operation_time"`
 }
 
+// Capability возвращает операцию, которую должен поддерживать агент, чтобы вычислить задачу:
+// для вызова встроенной функции — имя функции
+func (t Task) Capability() string {
+	if t.Operation == OperationCall {
+		return t.Function
+	}
+	return t.Operation
+}
+
 // TaskResult описание результата выполнения задачи
 type TaskResult struct {
 	// ID задачи
 	ID string `json:"id"`
+	// AgentID ID агента, которому задача выдана в аренду
+	AgentID string `json:"agent_id,omitempty"`
 	// Result результат выполнения задачи
-	Result float64 `json:"result"`
+	Result Float `json:"result"`
+	// Error описание ошибки вычисления (деление на ноль, результат не является конечным числом)
+	Error string `json:"error,omitempty"`
+	// Retryable признак ошибки, которая может не повториться при повторном выполнении задачи
+	// (например, агент не поддерживает операцию); такая задача выполняется повторно
+	Retryable bool `json:"retryable,omitempty"`
+}
+
+// TaskResultStatus код обработки одного результата из пакета результатов
+type TaskResultStatus struct {
+	// ID задачи
+	ID string `json:"id"`
+	// Status http-код обработки результата (200 — принят, 403 — агент не арендует задачу или в карантине,
+	// 404 — задача неизвестна, 409 — принят другой результат задачи)
+	Status int `json:"status"`
+}
+
+// DeadLetter задача, не выполненная за допустимое число попыток
+type DeadLetter struct {
+	// Task задача
+	Task Task `json:"task"`
+	// Attempts количество выполненных попыток
+	Attempts int `json:"attempts"`
+	// Reason причина последней неудачной попытки
+	Reason string `json:"reason"`
+	// FailedAt время перемещения задачи в очередь недоставленных задач
+	FailedAt time.Time `json:"failed_at"`
+}
+
+// QuarantinedAgent агент, результат которого разошелся с результатом, принятым по кворуму реплик
+type QuarantinedAgent struct {
+	// AgentID ID агента
+	AgentID string `json:"agent_id"`
+	// Tasks ID задач, результаты которых разошлись с принятыми
+	Tasks []string `json:"tasks"`
+	// QuarantinedAt время помещения агента в карантин
+	QuarantinedAt time.Time `json:"quarantined_at"`
+}
+
+// Agent описание агента, зарегистрированного у оркестратора
+type Agent struct {
+	// ID агента
+	ID string `json:"id"`
+	// Hostname имя хоста агента
+	Hostname string `json:"hostname"`
+	// Version версия агента
+	Version string `json:"version"`
+	// ComputingPower количество вычислителей агента
+	ComputingPower int `json:"computing_power"`
+	// RegisteredAt время регистрации агента
+	RegisteredAt time.Time `json:"registered_at"`
+	// LastHeartbeat время последнего сигнала активности агента
+	LastHeartbeat time.Time `json:"last_heartbeat"`
+	// Alive признак активности: сигнал активности получен не позже допустимого времени
+	Alive bool `json:"alive"`
+	// InFlight количество задач, выданных агенту и ожидающих результата
+	InFlight int `json:"in_flight"`
+	// Completed количество задач, результаты которых приняты от агента
+	Completed int `json:"completed"`
 }
 
 // TaskReceived принятая задача агентом
 type TaskReceived struct {
 	Task Task `json:"task"`
 }
+
+// TasksReceived пакет задач, принятый агентом
+type TasksReceived struct {
+	Tasks []Task `json:"tasks"`
+}
+
+// ExpressionError описание ошибки разбора математического выражения
+type ExpressionError struct {
+	// Code машиночитаемый код ошибки
+	Code string `json:"code"`
+	// Message описание ошибки
+	Message string `json:"message"`
+	// Position смещение ошибки от начала выражения в символах
+	Position int `json:"position"`
+	// Snippet фрагмент выражения и строка с указателем "^" на место ошибки
+	Snippet string `json:"snippet"`
+}
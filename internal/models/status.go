@@ -0,0 +1,74 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// transitions допустимые переходы между статусами выражения
+var transitions = map[string][]string{
+	// время вычисления может истечь до того, как выражение начнет вычисляться
+	StatusExpressionQueued: {StatusExpressionParsing, StatusExpressionCancelled, StatusExpressionTimedOut},
+	StatusExpressionParsing: {StatusExpressionRunning, StatusExpressionFailed, StatusExpressionCancelled,
+		StatusExpressionTimedOut},
+	StatusExpressionRunning: {StatusExpressionCompleted, StatusExpressionBlocked, StatusExpressionFailed,
+		StatusExpressionCancelled, StatusExpressionTimedOut},
+	// вычисление, остановленное задачей из очереди недоставленных задач, возобновляется, когда задача
+	// возвращается в очередь; до этого оно может быть отменено, по истечении времени ожидания задачи
+	// завершается ошибкой задачи, а по истечении времени вычисления выражения — статусом "timed_out"
+	StatusExpressionBlocked: {StatusExpressionRunning, StatusExpressionCancelled, StatusExpressionFailed,
+		StatusExpressionTimedOut},
+}
+
+// terminal статусы завершенного вычисления
+var terminal = map[string]bool{
+	StatusExpressionCompleted: true,
+	StatusExpressionFailed:    true,
+	StatusExpressionCancelled: true,
+	StatusExpressionTimedOut:  true,
+}
+
+// Transition переход выражения в новый статус
+type Transition struct {
+	// Status новый статус
+	Status string `json:"status"`
+	// At время перехода
+	At time.Time `json:"at"`
+}
+
+// CanTransition проверяет, допустим ли переход выражения из статуса from в статус to
+func CanTransition(from, to string) bool {
+	for _, status := range transitions[from] {
+		if status == to {
+			return true
+		}
+	}
+	return false
+}
+
+// IsTerminal проверяет, завершено ли вычисление выражения в статусе status: из такого статуса переходы недопустимы
+func IsTerminal(status string) bool {
+	return terminal[status]
+}
+
+// NewExpression создает выражение в статусе StatusExpressionQueued
+func NewExpression(id, expr string, at time.Time) Expression {
+	return Expression{
+		ID:          id,
+		Expr:        expr,
+		Status:      StatusExpressionQueued,
+		Transitions: []Transition{{Status: StatusExpressionQueued, At: at}},
+	}
+}
+
+// Transition переводит выражение в статус to, запоминая время перехода; причина ошибки
+// сохраняется только для статусов, описывающих неуспешное завершение
+func (e *Expression) Transition(to, reason string, at time.Time) error {
+	if !CanTransition(e.Status, to) {
+		return fmt.Errorf("invalid transition from %q to %q", e.Status, to)
+	}
+	e.Status = to
+	e.Reason = reason
+	e.Transitions = append(e.Transitions, Transition{Status: to, At: at})
+	return nil
+}
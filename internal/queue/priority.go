@@ -0,0 +1,117 @@
+package queue
+
+import (
+	"sort"
+	"time"
+
+	"github.com/ivanov-nikolay/distributed_calculator/internal/models"
+)
+
+// Priority очередь с приоритетами: задачи хранятся в очередях уровней приоритета, созданных newLevel,
+// и выдаются из уровня с наибольшим эффективным приоритетом. Эффективный приоритет уровня равен
+// приоритету задач уровня, увеличенному на единицу за каждый интервал aging, прошедший с момента,
+// когда уровень последний раз получал задачу; поэтому задачи с низким приоритетом не ожидают бесконечно
+type Priority struct {
+	aging    time.Duration
+	clock    func() time.Time
+	newLevel func() Queue
+	levels   map[int]*priorityLevel
+	// owners уровни приоритета задач в очереди
+	owners map[string]int
+}
+
+// priorityLevel очередь задач одного приоритета
+type priorityLevel struct {
+	tasks Queue
+	// waitingSince момент, с которого уровень ожидает выдачи задачи
+	waitingSince time.Time
+}
+
+// NewPriority создает пустую очередь с приоритетами; aging — интервал повышения приоритета
+// ожидающего уровня на единицу (0 — без повышения), clock — источник текущего времени
+func NewPriority(aging time.Duration, clock func() time.Time, newLevel func() Queue) *Priority {
+	return &Priority{
+		aging:    aging,
+		clock:    clock,
+		newLevel: newLevel,
+		levels:   make(map[int]*priorityLevel),
+		owners:   make(map[string]int),
+	}
+}
+
+// Push добавляет задачу в очередь уровня ее приоритета
+func (q *Priority) Push(task models.Task) {
+	if _, exists := q.owners[task.ID]; exists {
+		return
+	}
+	level, exists := q.levels[task.Priority]
+	if !exists {
+		level = &priorityLevel{tasks: q.newLevel(), waitingSince: q.clock()}
+		q.levels[task.Priority] = level
+	}
+	level.tasks.Push(task)
+	q.owners[task.ID] = task.Priority
+}
+
+// Pop извлекает задачу из уровня с наибольшим эффективным приоритетом; при равенстве
+// предпочитается уровень с большим собственным приоритетом
+func (q *Priority) Pop() (models.Task, bool) {
+	return q.PopFirst(func(models.Task) bool { return true })
+}
+
+// PopFirst извлекает первую подходящую задачу, просматривая уровни в порядке убывания эффективного
+// приоритета; время ожидания сбрасывается только у уровня, выдавшего задачу
+func (q *Priority) PopFirst(match func(models.Task) bool) (models.Task, bool) {
+	now := q.clock()
+
+	scores := make(map[int]int64, len(q.levels))
+	priorities := make([]int, 0, len(q.levels))
+	for priority, level := range q.levels {
+		score := int64(priority)
+		if q.aging > 0 {
+			score += int64(now.Sub(level.waitingSince) / q.aging)
+		}
+		scores[priority] = score
+		priorities = append(priorities, priority)
+	}
+	sort.Slice(priorities, func(i, j int) bool {
+		a, b := priorities[i], priorities[j]
+		return scores[a] > scores[b] || (scores[a] == scores[b] && a > b)
+	})
+
+	for _, priority := range priorities {
+		level := q.levels[priority]
+		task, found := level.tasks.PopFirst(match)
+		if !found {
+			continue
+		}
+		delete(q.owners, task.ID)
+		level.waitingSince = now
+		if level.tasks.Len() == 0 {
+			delete(q.levels, priority)
+		}
+		return task, true
+	}
+	return models.Task{}, false
+}
+
+// Remove удаляет задачу из очереди
+func (q *Priority) Remove(id string) bool {
+	priority, exists := q.owners[id]
+	if !exists {
+		return false
+	}
+	delete(q.owners, id)
+
+	level := q.levels[priority]
+	level.tasks.Remove(id)
+	if level.tasks.Len() == 0 {
+		delete(q.levels, priority)
+	}
+	return true
+}
+
+// Len возвращает количество задач в очереди
+func (q *Priority) Len() int {
+	return len(q.owners)
+}
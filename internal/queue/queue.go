@@ -0,0 +1,198 @@
+package queue
+
+import (
+	"container/list"
+	"fmt"
+
+	"github.com/ivanov-nikolay/distributed_calculator/internal/models"
+)
+
+// Политики выдачи задач агентам
+const (
+	// PolicyFIFO задачи выдаются в порядке постановки в очередь
+	PolicyFIFO = "fifo"
+	// PolicyRoundRobin выражения обслуживаются по очереди, по одной задаче от каждого;
+	// задачи одного выражения выдаются в порядке постановки в очередь
+	PolicyRoundRobin = "round_robin"
+)
+
+// Queue очередь задач, ожидающих выдачи агентам. Задача с ID, уже находящимся в очереди,
+// повторно не добавляется. Реализации не синхронизированы: доступ к очереди синхронизирует вызывающий
+type Queue interface {
+	// Push добавляет задачу в очередь
+	Push(task models.Task)
+	// Pop извлекает следующую задачу согласно политике очереди
+	Pop() (models.Task, bool)
+	// PopFirst извлекает первую в порядке выдачи задачу, для которой match возвращает true;
+	// пропущенные задачи сохраняют свои места в очереди
+	PopFirst(match func(models.Task) bool) (models.Task, bool)
+	// Remove удаляет задачу из очереди, возвращает false, если задачи в очереди нет
+	Remove(id string) bool
+	// Len возвращает количество задач в очереди
+	Len() int
+}
+
+// New создает очередь с указанной политикой выдачи задач
+func New(policy string) (Queue, error) {
+	switch policy {
+	case PolicyFIFO:
+		return NewFIFO(), nil
+	case PolicyRoundRobin:
+		return NewRoundRobin(), nil
+	default:
+		return nil, fmt.Errorf("unknown queue policy %q", policy)
+	}
+}
+
+// FIFO очередь, выдающая задачи в порядке постановки
+type FIFO struct {
+	order    *list.List
+	elements map[string]*list.Element
+}
+
+// NewFIFO создает пустую очередь FIFO
+func NewFIFO() *FIFO {
+	return &FIFO{order: list.New(), elements: make(map[string]*list.Element)}
+}
+
+// Push добавляет задачу в конец очереди
+func (q *FIFO) Push(task models.Task) {
+	if _, exists := q.elements[task.ID]; exists {
+		return
+	}
+	q.elements[task.ID] = q.order.PushBack(task)
+}
+
+// Pop извлекает задачу из начала очереди
+func (q *FIFO) Pop() (models.Task, bool) {
+	front := q.order.Front()
+	if front == nil {
+		return models.Task{}, false
+	}
+	task := q.order.Remove(front).(models.Task)
+	delete(q.elements, task.ID)
+	return task, true
+}
+
+// PopFirst извлекает ближайшую к началу очереди задачу, для которой match возвращает true
+func (q *FIFO) PopFirst(match func(models.Task) bool) (models.Task, bool) {
+	for element := q.order.Front(); element != nil; element = element.Next() {
+		task := element.Value.(models.Task)
+		if !match(task) {
+			continue
+		}
+		q.order.Remove(element)
+		delete(q.elements, task.ID)
+		return task, true
+	}
+	return models.Task{}, false
+}
+
+// Remove удаляет задачу из очереди
+func (q *FIFO) Remove(id string) bool {
+	element, exists := q.elements[id]
+	if !exists {
+		return false
+	}
+	q.order.Remove(element)
+	delete(q.elements, id)
+	return true
+}
+
+// Len возвращает количество задач в очереди
+func (q *FIFO) Len() int {
+	return q.order.Len()
+}
+
+// RoundRobin очередь, поочередно выдающая задачи разных выражений, чтобы выражение с большим
+// числом готовых задач не задерживало остальные
+type RoundRobin struct {
+	// ring выражения с задачами в очереди в порядке обслуживания
+	ring *list.List
+	// expressions очереди задач выражений и их места в ring
+	expressions map[string]*list.Element
+	// owners выражения, которым принадлежат задачи в очереди
+	owners map[string]string
+}
+
+// expressionQueue задачи одного выражения
+type expressionQueue struct {
+	id    string
+	tasks *FIFO
+}
+
+// NewRoundRobin создает пустую очередь с поочередным обслуживанием выражений
+func NewRoundRobin() *RoundRobin {
+	return &RoundRobin{
+		ring:        list.New(),
+		expressions: make(map[string]*list.Element),
+		owners:      make(map[string]string),
+	}
+}
+
+// Push добавляет задачу в конец очереди ее выражения; выражение без задач в очереди
+// становится в конец очереди обслуживания
+func (q *RoundRobin) Push(task models.Task) {
+	if _, exists := q.owners[task.ID]; exists {
+		return
+	}
+	element, exists := q.expressions[task.ExpressionID]
+	if !exists {
+		element = q.ring.PushBack(&expressionQueue{id: task.ExpressionID, tasks: NewFIFO()})
+		q.expressions[task.ExpressionID] = element
+	}
+	element.Value.(*expressionQueue).tasks.Push(task)
+	q.owners[task.ID] = task.ExpressionID
+}
+
+// Pop извлекает задачу выражения, стоящего первым в очереди обслуживания, и переводит
+// выражение в конец очереди обслуживания
+func (q *RoundRobin) Pop() (models.Task, bool) {
+	return q.PopFirst(func(models.Task) bool { return true })
+}
+
+// PopFirst извлекает первую подходящую задачу выражения, стоящего ближе всех к началу очереди
+// обслуживания, и переводит это выражение в конец очереди обслуживания; выражения без подходящих
+// задач остаются на своих местах
+func (q *RoundRobin) PopFirst(match func(models.Task) bool) (models.Task, bool) {
+	for element := q.ring.Front(); element != nil; element = element.Next() {
+		expression := element.Value.(*expressionQueue)
+		task, found := expression.tasks.PopFirst(match)
+		if !found {
+			continue
+		}
+		delete(q.owners, task.ID)
+
+		if expression.tasks.Len() == 0 {
+			q.ring.Remove(element)
+			delete(q.expressions, expression.id)
+		} else {
+			q.ring.MoveToBack(element)
+		}
+		return task, true
+	}
+	return models.Task{}, false
+}
+
+// Remove удаляет задачу из очереди
+func (q *RoundRobin) Remove(id string) bool {
+	owner, exists := q.owners[id]
+	if !exists {
+		return false
+	}
+	delete(q.owners, id)
+
+	element := q.expressions[owner]
+	expression := element.Value.(*expressionQueue)
+	expression.tasks.Remove(id)
+	if expression.tasks.Len() == 0 {
+		q.ring.Remove(element)
+		delete(q.expressions, owner)
+	}
+	return true
+}
+
+// Len возвращает количество задач в очереди
+func (q *RoundRobin) Len() int {
+	return len(q.owners)
+}
@@ -0,0 +1,46 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/ivanov-nikolay/distributed_calculator/internal/ast"
+	"github.com/ivanov-nikolay/distributed_calculator/internal/models"
+)
+
+// Evaluate вычисляет дерево разбора: каждая операция передается функции compute отдельной задачей,
+// задачи независимых подвыражений выполняются параллельно (см. Graph.Execute). Промежуточные
+// результаты передаются между задачами как float64 без преобразований, поэтому результат
+// не зависит от того, вычисляются ли задачи агентами или локально, и от порядка их завершения.
+// Ошибка задачи возвращается в виде *TaskError
+func Evaluate(node ast.Node, variables map[string]float64, compute func(models.Task) (float64, error)) (float64, error) {
+	graph, err := BuildGraph(node, variables)
+	if err != nil {
+		return 0, err
+	}
+	return graph.Execute(compute)
+}
+
+// TaskError ошибка задачи операции выражения с указанием места операции
+type TaskError struct {
+	// Err ошибка выполнения задачи
+	Err error
+	// Pos смещение операции от начала выражения в байтах
+	Pos int
+}
+
+func (e *TaskError) Error() string {
+	return fmt.Sprintf("%v at position %d", e.Err, e.Pos)
+}
+
+func (e *TaskError) Unwrap() error {
+	return e.Err
+}
+
+// computeAt вычисляет задачу операции node, дополняя ошибку позицией операции в выражении
+func computeAt(node ast.Node, compute func(models.Task) (float64, error), task models.Task) (float64, error) {
+	result, err := compute(task)
+	if err != nil {
+		return 0, &TaskError{Err: err, Pos: node.Pos()}
+	}
+	return result, nil
+}
@@ -0,0 +1,38 @@
+package service
+
+import "fmt"
+
+// VariadicArgs признак функции с неограниченным числом аргументов
+const VariadicArgs = -1
+
+// Function описание встроенной функции
+type Function struct {
+	// MinArgs минимальное количество аргументов
+	MinArgs int
+	// MaxArgs максимальное количество аргументов (VariadicArgs — без ограничения)
+	MaxArgs int
+}
+
+// Functions реестр встроенных функций, доступных в выражениях
+var Functions = map[string]Function{
+	"sqrt": {MinArgs: 1, MaxArgs: 1},
+	"abs":  {MinArgs: 1, MaxArgs: 1},
+	"sin":  {MinArgs: 1, MaxArgs: 1},
+	"cos":  {MinArgs: 1, MaxArgs: 1},
+	// log(x) — натуральный логарифм, log(x, base) — логарифм по основанию base
+	"log": {MinArgs: 1, MaxArgs: 2},
+	"min": {MinArgs: 1, MaxArgs: VariadicArgs},
+	"max": {MinArgs: 1, MaxArgs: VariadicArgs},
+}
+
+// CheckFunctionCall проверяет, что функция зарегистрирована и принимает указанное число аргументов
+func CheckFunctionCall(name string, argc int) error {
+	fn, exists := Functions[name]
+	if !exists {
+		return fmt.Errorf("unknown function %q", name)
+	}
+	if argc < fn.MinArgs || (fn.MaxArgs != VariadicArgs && argc > fn.MaxArgs) {
+		return fmt.Errorf("function %q called with %d arguments", name, argc)
+	}
+	return nil
+}
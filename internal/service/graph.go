@@ -0,0 +1,178 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/ivanov-nikolay/distributed_calculator/internal/ast"
+	"github.com/ivanov-nikolay/distributed_calculator/internal/models"
+)
+
+// Operand операнд операции графа: известное значение или результат другой операции
+type Operand struct {
+	// Value значение операнда, если Source < 0
+	Value float64
+	// Source индекс операции графа, результатом которой является операнд, или -1
+	Source int
+}
+
+// Operation вершина графа зависимостей — одна задача вычисления
+type Operation struct {
+	// Node узел дерева разбора, которому соответствует операция
+	Node ast.Node
+	// Operation операция задачи
+	Operation string
+	// Function имя функции (только для models.OperationCall)
+	Function string
+	// Inputs операнды операции в порядке аргументов
+	Inputs []Operand
+	// Dependents индексы операций, использующих результат этой операции
+	Dependents []int
+}
+
+// Graph граф зависимостей задач математического выражения. Операции перечислены
+// в порядке обхода дерева, поэтому операнды всегда предшествуют использующим их операциям
+type Graph struct {
+	// Operations операции выражения
+	Operations []*Operation
+	// Root результат выражения
+	Root Operand
+}
+
+// BuildGraph строит граф зависимостей задач по дереву разбора; переменные и константы
+// подставляются в операнды сразу
+func BuildGraph(node ast.Node, variables map[string]float64) (*Graph, error) {
+	graph := &Graph{}
+	root, err := graph.add(node, variables)
+	if err != nil {
+		return nil, err
+	}
+	graph.Root = root
+	return graph, nil
+}
+
+// add добавляет в граф операции поддерева node и возвращает операнд с его результатом
+func (g *Graph) add(node ast.Node, variables map[string]float64) (Operand, error) {
+	var (
+		operation = &Operation{Node: node}
+		operands  []ast.Node
+	)
+
+	switch n := node.(type) {
+	case *ast.Number:
+		return Operand{Value: n.Value, Source: -1}, nil
+	case *ast.Variable:
+		value, exists := ResolveIdentifier(n.Name, variables)
+		if !exists {
+			return Operand{}, fmt.Errorf("unbound identifier %q", n.Name)
+		}
+		return Operand{Value: value, Source: -1}, nil
+	case *ast.Unary:
+		operation.Operation = OperatorNegation
+		operands = []ast.Node{n.Operand}
+	case *ast.Binary:
+		operation.Operation = n.Operator
+		operands = []ast.Node{n.Left, n.Right}
+	case *ast.Call:
+		operation.Operation = models.OperationCall
+		operation.Function = n.Function
+		operands = n.Args
+	default:
+		return Operand{}, fmt.Errorf("unsupported node %T", node)
+	}
+
+	for _, operand := range operands {
+		input, err := g.add(operand, variables)
+		if err != nil {
+			return Operand{}, err
+		}
+		operation.Inputs = append(operation.Inputs, input)
+	}
+
+	index := len(g.Operations)
+	for _, input := range operation.Inputs {
+		if input.Source >= 0 {
+			g.Operations[input.Source].Dependents = append(g.Operations[input.Source].Dependents, index)
+		}
+	}
+	g.Operations = append(g.Operations, operation)
+	return Operand{Source: index}, nil
+}
+
+// Execute вычисляет граф: все операции, операнды которых известны, передаются функции compute
+// одновременно, поэтому независимые подвыражения вычисляются параллельно. При ошибке задачи
+// новые задачи не создаются, а ошибка дополняется позицией операции
+func (g *Graph) Execute(compute func(models.Task) (float64, error)) (float64, error) {
+	if g.Root.Source < 0 {
+		return g.Root.Value, nil
+	}
+
+	type outcome struct {
+		index int
+		value float64
+		err   error
+	}
+
+	// канал с буфером на все операции: задачи, завершившиеся после ошибки, не блокируются
+	done := make(chan outcome, len(g.Operations))
+	values := make([]float64, len(g.Operations))
+	waiting := make([]int, len(g.Operations))
+
+	start := func(index int) {
+		task := g.task(index, values)
+		go func() {
+			value, err := computeAt(g.Operations[index].Node, compute, task)
+			done <- outcome{index: index, value: value, err: err}
+		}()
+	}
+
+	for index, operation := range g.Operations {
+		for _, input := range operation.Inputs {
+			if input.Source >= 0 {
+				waiting[index]++
+			}
+		}
+		if waiting[index] == 0 {
+			start(index)
+		}
+	}
+
+	for range g.Operations {
+		result := <-done
+		if result.err != nil {
+			return 0, result.err
+		}
+		values[result.index] = result.value
+		for _, dependent := range g.Operations[result.index].Dependents {
+			waiting[dependent]--
+			if waiting[dependent] == 0 {
+				start(dependent)
+			}
+		}
+	}
+
+	return values[g.Root.Source], nil
+}
+
+// task формирует задачу операции index по известным значениям операндов
+func (g *Graph) task(index int, values []float64) models.Task {
+	operation := g.Operations[index]
+	args := make([]float64, 0, len(operation.Inputs))
+	for _, input := range operation.Inputs {
+		if input.Source >= 0 {
+			args = append(args, values[input.Source])
+		} else {
+			args = append(args, input.Value)
+		}
+	}
+
+	task := models.Task{Operation: operation.Operation, Function: operation.Function}
+	switch {
+	case operation.Operation == models.OperationCall:
+		task.Args = models.Floats(args)
+	case len(args) == 1:
+		task.Arg1 = models.Float(args[0])
+	default:
+		task.Arg1, task.Arg2 = models.Float(args[0]), models.Float(args[1])
+	}
+	return task
+}
@@ -0,0 +1,37 @@
+package service
+
+import (
+	"math"
+	"unicode"
+)
+
+// Constants встроенные именованные константы
+var Constants = map[string]float64{
+	"pi":  math.Pi,
+	"e":   math.E,
+	"tau": 2 * math.Pi,
+}
+
+// ResolveIdentifier возвращает значение переменной или встроенной константы.
+// Переменные запроса имеют приоритет над встроенными константами
+func ResolveIdentifier(name string, variables map[string]float64) (float64, bool) {
+	if value, exists := variables[name]; exists {
+		return value, true
+	}
+	value, exists := Constants[name]
+	return value, exists
+}
+
+// IsIdentifier проверяет токен на соответствие идентификатору (имени функции, константы или переменной)
+func IsIdentifier(token string) bool {
+	if token == "" {
+		return false
+	}
+	for i, char := range token {
+		if char == '_' || unicode.IsLetter(char) || (i > 0 && unicode.IsDigit(char)) {
+			continue
+		}
+		return false
+	}
+	return true
+}
@@ -0,0 +1,276 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// TokenKind вид лексемы
+type TokenKind int
+
+// Виды лексем
+const (
+	TokenNumber TokenKind = iota
+	TokenIdentifier
+	TokenOperator
+	TokenLeftParen
+	TokenRightParen
+	TokenComma
+)
+
+// LiteralKind вид числового литерала
+type LiteralKind int
+
+// Виды числовых литералов
+const (
+	// LiteralInteger целое десятичное число: 42, 1_000
+	LiteralInteger LiteralKind = iota
+	// LiteralDecimal десятичная дробь: 3.14, .5, 2.
+	LiteralDecimal
+	// LiteralScientific число с порядком: 1e-3, 1.5E+10
+	LiteralScientific
+	// LiteralHex шестнадцатеричное целое: 0x1F
+	LiteralHex
+	// LiteralBinary двоичное целое: 0b1010
+	LiteralBinary
+	// LiteralOctal восьмеричное целое: 0o17
+	LiteralOctal
+)
+
+// operatorSpellings написания операторов во входном выражении;
+// многосимвольные написания проверяются первыми, чтобы "//" не распознавался как два "/"
+var operatorSpellings = []struct {
+	spelling string
+	operator string
+}{
+	{"**", OperatorPower},
+	{"//", OperatorIntDivision},
+	{"+", "+"},
+	{"-", "-"},
+	{"*", "*"},
+	{"/", "/"},
+	{"^", OperatorPower},
+	{"%", OperatorModulo},
+}
+
+// Token лексема математического выражения
+type Token struct {
+	// Kind вид лексемы
+	Kind TokenKind
+	// Text написание лексемы; для операторов — нормализованный оператор ("**" записывается как "^")
+	Text string
+	// Literal вид числового литерала (только для TokenNumber)
+	Literal LiteralKind
+	// Value значение числового литерала (только для TokenNumber)
+	Value float64
+	// Pos смещение лексемы от начала выражения в байтах
+	Pos int
+}
+
+// Lex разбивает выражение на лексемы, пробельные символы пропускаются
+func Lex(expr string) ([]Token, error) {
+	var tokens []Token
+
+	for i := 0; i < len(expr); {
+		char, size := utf8.DecodeRuneInString(expr[i:])
+
+		switch {
+		case unicode.IsSpace(char):
+			i += size
+		case isDigit(expr[i]) || (expr[i] == '.' && i+1 < len(expr) && isDigit(expr[i+1])):
+			token, err := lexNumber(expr, i)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token)
+			i += len(token.Text)
+		case char == '_' || unicode.IsLetter(char):
+			start := i
+			for i < len(expr) {
+				char, size = utf8.DecodeRuneInString(expr[i:])
+				if char != '_' && !unicode.IsLetter(char) && !unicode.IsDigit(char) {
+					break
+				}
+				i += size
+			}
+			tokens = append(tokens, Token{Kind: TokenIdentifier, Text: expr[start:i], Pos: start})
+		case char == '(':
+			tokens = append(tokens, Token{Kind: TokenLeftParen, Text: "(", Pos: i})
+			i++
+		case char == ')':
+			tokens = append(tokens, Token{Kind: TokenRightParen, Text: ")", Pos: i})
+			i++
+		case char == ',':
+			tokens = append(tokens, Token{Kind: TokenComma, Text: ",", Pos: i})
+			i++
+		default:
+			operator, length := matchOperator(expr[i:])
+			if length == 0 {
+				return nil, &ParseError{
+					Code:    ErrorUnexpectedCharacter,
+					Pos:     i,
+					Message: fmt.Sprintf("unexpected character %q", char),
+				}
+			}
+			tokens = append(tokens, Token{Kind: TokenOperator, Text: operator, Pos: i})
+			i += length
+		}
+	}
+
+	return tokens, nil
+}
+
+// matchOperator ищет оператор в начале строки, возвращает оператор и длину его написания
+func matchOperator(s string) (string, int) {
+	for _, op := range operatorSpellings {
+		if strings.HasPrefix(s, op.spelling) {
+			return op.operator, len(op.spelling)
+		}
+	}
+	return "", 0
+}
+
+// lexNumber читает числовой литерал, начинающийся с позиции start
+func lexNumber(expr string, start int) (Token, error) {
+	token := Token{Kind: TokenNumber, Literal: LiteralInteger, Pos: start}
+
+	i := start
+	if expr[i] == '0' && i+1 < len(expr) {
+		base, literal := 0, LiteralInteger
+		switch expr[i+1] {
+		case 'x', 'X':
+			base, literal = 16, LiteralHex
+		case 'b', 'B':
+			base, literal = 2, LiteralBinary
+		case 'o', 'O':
+			base, literal = 8, LiteralOctal
+		}
+		if base != 0 {
+			end, err := scanDigits(expr, i+2, base)
+			if err != nil {
+				return Token{}, err
+			}
+			if err := checkLiteralEnd(expr, end); err != nil {
+				return Token{}, err
+			}
+			if end == i+2 {
+				return Token{}, &ParseError{Code: ErrorInvalidNumber, Pos: start, Message: "missing digits after base prefix"}
+			}
+
+			// целое произвольной длины округляется до ближайшего float64 один раз
+			digits, _ := new(big.Int).SetString(strings.ReplaceAll(expr[i+2:end], "_", ""), base)
+			token.Value, _ = new(big.Float).SetInt(digits).Float64()
+			if math.IsInf(token.Value, 0) {
+				return Token{}, &ParseError{
+					Code:    ErrorInvalidNumber,
+					Pos:     start,
+					Message: fmt.Sprintf("number %q out of range", expr[start:end]),
+				}
+			}
+			token.Text = expr[start:end]
+			token.Literal = literal
+			return token, nil
+		}
+	}
+
+	// десятичный литерал: целая часть, дробная часть, порядок
+	end, err := scanDigits(expr, i, 10)
+	if err != nil {
+		return Token{}, err
+	}
+	if end < len(expr) && expr[end] == '.' {
+		token.Literal = LiteralDecimal
+		end, err = scanDigits(expr, end+1, 10)
+		if err != nil {
+			return Token{}, err
+		}
+	}
+	if end < len(expr) && (expr[end] == 'e' || expr[end] == 'E') {
+		token.Literal = LiteralScientific
+		exponent := end + 1
+		if exponent < len(expr) && (expr[exponent] == '+' || expr[exponent] == '-') {
+			exponent++
+		}
+		end, err = scanDigits(expr, exponent, 10)
+		if err != nil {
+			return Token{}, err
+		}
+		if end == exponent {
+			return Token{}, &ParseError{Code: ErrorInvalidNumber, Pos: exponent, Message: "missing digits in exponent"}
+		}
+	}
+	if err := checkLiteralEnd(expr, end); err != nil {
+		return Token{}, err
+	}
+
+	token.Text = expr[start:end]
+	token.Value, err = strconv.ParseFloat(strings.ReplaceAll(token.Text, "_", ""), 64)
+	if err != nil {
+		// ParseFloat возвращает ±Inf при переполнении, такие литералы не принимаются
+		return Token{}, &ParseError{Code: ErrorInvalidNumber, Pos: start, Message: fmt.Sprintf("number %q out of range", token.Text)}
+	}
+	return token, nil
+}
+
+// scanDigits читает цифры системы счисления base, разделенные одиночными подчеркиваниями;
+// возвращает позицию первого символа после цифр
+func scanDigits(expr string, start, base int) (int, error) {
+	i := start
+	for i < len(expr) {
+		if expr[i] == '_' {
+			// подчеркивание допустимо только между цифрами
+			if i == start || i+1 >= len(expr) || !isDigitOfBase(expr[i+1], base) || !isDigitOfBase(expr[i-1], base) {
+				return 0, &ParseError{Code: ErrorInvalidNumber, Pos: i, Message: "misplaced digit separator '_'"}
+			}
+			i++
+			continue
+		}
+		if !isDigitOfBase(expr[i], base) {
+			break
+		}
+		i++
+	}
+	return i, nil
+}
+
+// checkLiteralEnd проверяет, что литерал не продолжается буквами или цифрами (например, 0b102 или 2x)
+func checkLiteralEnd(expr string, end int) error {
+	if end >= len(expr) {
+		return nil
+	}
+	char, _ := utf8.DecodeRuneInString(expr[end:])
+	if char == '_' || char == '.' || unicode.IsLetter(char) || unicode.IsDigit(char) {
+		return &ParseError{Code: ErrorInvalidNumber, Pos: end, Message: fmt.Sprintf("unexpected character %q in number", char)}
+	}
+	return nil
+}
+
+// isDigit проверяет, является ли символ десятичной цифрой
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// isDigitOfBase проверяет, является ли символ цифрой системы счисления base
+func isDigitOfBase(c byte, base int) bool {
+	value := digitValue(c)
+	return value >= 0 && value < base
+}
+
+// digitValue возвращает значение цифры (до шестнадцатеричной) или -1
+func digitValue(c byte) int {
+	switch {
+	case c >= '0' && c <= '9':
+		return int(c - '0')
+	case c >= 'a' && c <= 'f':
+		return int(c-'a') + 10
+	case c >= 'A' && c <= 'F':
+		return int(c-'A') + 10
+	default:
+		return -1
+	}
+}
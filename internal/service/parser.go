@@ -0,0 +1,201 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/ivanov-nikolay/distributed_calculator/internal/ast"
+)
+
+// MaxNestingDepth наибольшая глубина вложенности выражения (скобки, унарные операции, аргументы функций,
+// операторы более высокого приоритета): более глубокое выражение исчерпало бы стек рекурсивного разбора
+const MaxNestingDepth = 1000
+
+// parser рекурсивный нисходящий разбор выражения с приоритетами операторов (Pratt parser)
+type parser struct {
+	// expr исходное выражение
+	expr string
+	// tokens лексемы выражения
+	tokens []Token
+	// next индекс следующей неразобранной лексемы
+	next int
+	// depth глубина вложенности разбираемого подвыражения
+	depth int
+}
+
+// Parse разбирает выражение в дерево; ошибка возвращается в виде *ParseError.
+// Унарный плюс отбрасывается, унарный минус перед числом сворачивается в отрицательный литерал
+func Parse(expr string) (ast.Node, error) {
+	tokens, err := Lex(expr)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, &ParseError{Code: ErrorEmptyExpression, Message: "empty expression", Pos: 0}
+	}
+
+	p := &parser{expr: expr, tokens: tokens}
+	node, err := p.parseExpression(0)
+	if err != nil {
+		return nil, err
+	}
+
+	if token, ok := p.peek(); ok {
+		switch token.Kind {
+		case TokenRightParen:
+			return nil, &ParseError{Code: ErrorUnbalancedParen, Message: "unmatched ')'", Pos: token.Pos}
+		case TokenComma:
+			return nil, unexpectedToken(token, "',' outside of function call")
+		default:
+			return nil, unexpectedToken(token, "expected operator")
+		}
+	}
+
+	return node, nil
+}
+
+// parseExpression разбирает выражение, пока встречаются бинарные операторы с приоритетом выше minPrecedence
+func (p *parser) parseExpression(minPrecedence int) (ast.Node, error) {
+	p.depth++
+	defer func() { p.depth-- }()
+	if p.depth > MaxNestingDepth {
+		pos := len(p.expr)
+		if token, ok := p.peek(); ok {
+			pos = token.Pos
+		}
+		return nil, &ParseError{
+			Code:    ErrorNestingTooDeep,
+			Message: fmt.Sprintf("expression is nested deeper than %d levels", MaxNestingDepth),
+			Pos:     pos,
+		}
+	}
+
+	left, err := p.parsePrefix()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		token, ok := p.peek()
+		if !ok || token.Kind != TokenOperator || precedence[token.Text] <= minPrecedence {
+			return left, nil
+		}
+		p.next++
+
+		// правоассоциативный оператор разбирает правый операнд с тем же приоритетом: 2^3^2 = 2^(3^2)
+		rightPrecedence := precedence[token.Text]
+		if IsRightAssociative(token.Text) {
+			rightPrecedence--
+		}
+		right, err := p.parseExpression(rightPrecedence)
+		if err != nil {
+			return nil, err
+		}
+		left = &ast.Binary{Operator: token.Text, Left: left, Right: right, Position: token.Pos}
+	}
+}
+
+// parsePrefix разбирает операнд: литерал, идентификатор, вызов функции, выражение в скобках или унарную операцию
+func (p *parser) parsePrefix() (ast.Node, error) {
+	token, ok := p.peek()
+	if !ok {
+		return nil, &ParseError{Code: ErrorUnexpectedEnd, Message: "unexpected end of expression", Pos: len(p.expr)}
+	}
+	p.next++
+
+	switch {
+	case token.Kind == TokenNumber:
+		return &ast.Number{Value: token.Value, Position: token.Pos}, nil
+	case token.Kind == TokenIdentifier:
+		if next, ok := p.peek(); ok && next.Kind == TokenLeftParen {
+			return p.parseCall(token)
+		}
+		return &ast.Variable{Name: token.Text, Position: token.Pos}, nil
+	case token.Kind == TokenLeftParen:
+		node, err := p.parseExpression(0)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expectClosing(token, "expected operator or ')'"); err != nil {
+			return nil, err
+		}
+		return node, nil
+	case token.Kind == TokenOperator && (token.Text == "+" || token.Text == "-"):
+		operand, err := p.parseExpression(precedenceUnary)
+		if err != nil {
+			return nil, err
+		}
+		if token.Text == "+" {
+			return operand, nil
+		}
+		if number, ok := operand.(*ast.Number); ok {
+			return &ast.Number{Value: -number.Value, Position: token.Pos}, nil
+		}
+		return &ast.Unary{Operator: token.Text, Operand: operand, Position: token.Pos}, nil
+	default:
+		p.next--
+		return nil, unexpectedToken(token, "expected number, identifier or '('")
+	}
+}
+
+// parseCall разбирает аргументы вызова функции и проверяет их количество
+func (p *parser) parseCall(name Token) (ast.Node, error) {
+	if _, exists := Functions[name.Text]; !exists {
+		return nil, &ParseError{
+			Code:    ErrorUnknownFunction,
+			Message: fmt.Sprintf("unknown function %q", name.Text),
+			Pos:     name.Pos,
+		}
+	}
+
+	paren := p.tokens[p.next]
+	p.next++
+
+	call := &ast.Call{Function: name.Text, Position: name.Pos}
+	if token, ok := p.peek(); !ok || token.Kind != TokenRightParen {
+		for {
+			arg, err := p.parseExpression(0)
+			if err != nil {
+				return nil, err
+			}
+			call.Args = append(call.Args, arg)
+
+			if token, ok := p.peek(); ok && token.Kind == TokenComma {
+				p.next++
+				continue
+			}
+			break
+		}
+	}
+	if err := p.expectClosing(paren, "expected ',' or ')'"); err != nil {
+		return nil, err
+	}
+
+	if err := CheckFunctionCall(call.Function, len(call.Args)); err != nil {
+		return nil, &ParseError{Code: ErrorArgumentCount, Message: err.Error(), Pos: paren.Pos}
+	}
+	return call, nil
+}
+
+// expectClosing проверяет, что следующая лексема закрывает скобку paren
+func (p *parser) expectClosing(paren Token, expectation string) error {
+	token, ok := p.peek()
+	switch {
+	case !ok:
+		return &ParseError{Code: ErrorUnbalancedParen, Message: "unclosed '('", Pos: paren.Pos}
+	case token.Kind == TokenRightParen:
+		p.next++
+		return nil
+	case token.Kind == TokenComma:
+		return unexpectedToken(token, "',' outside of function call")
+	default:
+		return unexpectedToken(token, expectation)
+	}
+}
+
+// peek возвращает следующую лексему, не продвигаясь по выражению
+func (p *parser) peek() (Token, bool) {
+	if p.next >= len(p.tokens) {
+		return Token{}, false
+	}
+	return p.tokens[p.next], true
+}
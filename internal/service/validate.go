@@ -0,0 +1,88 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ivanov-nikolay/distributed_calculator/internal/ast"
+)
+
+// Коды ошибок разбора выражения
+const (
+	ErrorEmptyExpression     = "empty_expression"
+	ErrorUnexpectedCharacter = "unexpected_character"
+	ErrorInvalidNumber       = "invalid_number"
+	ErrorUnexpectedToken     = "unexpected_token"
+	ErrorUnexpectedEnd       = "unexpected_end"
+	ErrorUnbalancedParen     = "unbalanced_parenthesis"
+	ErrorUnknownFunction     = "unknown_function"
+	ErrorArgumentCount       = "wrong_argument_count"
+	ErrorUnboundIdentifier   = "unbound_identifier"
+	ErrorNestingTooDeep      = "nesting_too_deep"
+)
+
+// ParseError ошибка разбора выражения с указанием места ошибки
+type ParseError struct {
+	// Code машиночитаемый код ошибки
+	Code string
+	// Message описание ошибки
+	Message string
+	// Pos смещение ошибочного фрагмента от начала выражения в байтах
+	Pos int
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%s at position %d", e.Message, e.Pos)
+}
+
+// ValidateExpression разбирает выражение и проверяет наличие значений у всех идентификаторов;
+// ошибка возвращается в виде *ParseError
+func ValidateExpression(expr string, variables map[string]float64) (ast.Node, error) {
+	node, err := Parse(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	// unbound позиции первых вхождений несвязанных идентификаторов
+	unbound := map[string]int{}
+	ast.Walk(node, func(n ast.Node) {
+		variable, ok := n.(*ast.Variable)
+		if !ok {
+			return
+		}
+		if _, exists := ResolveIdentifier(variable.Name, variables); exists {
+			return
+		}
+		if pos, seen := unbound[variable.Name]; !seen || variable.Position < pos {
+			unbound[variable.Name] = variable.Position
+		}
+	})
+
+	if len(unbound) > 0 {
+		names := make([]string, 0, len(unbound))
+		// позиция указывает на первое по тексту несвязанное имя
+		pos := len(expr)
+		for name, p := range unbound {
+			names = append(names, name)
+			pos = min(pos, p)
+		}
+		sort.Strings(names)
+		return nil, &ParseError{
+			Code:    ErrorUnboundIdentifier,
+			Message: "unbound identifiers: " + strings.Join(names, ", "),
+			Pos:     pos,
+		}
+	}
+
+	return node, nil
+}
+
+// unexpectedToken формирует ошибку для лексемы, недопустимой в текущей позиции
+func unexpectedToken(token Token, expectation string) *ParseError {
+	return &ParseError{
+		Code:    ErrorUnexpectedToken,
+		Message: fmt.Sprintf("unexpected %q: %s", token.Text, expectation),
+		Pos:     token.Pos,
+	}
+}
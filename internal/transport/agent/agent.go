@@ -1,65 +1,126 @@
 package agent
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
-	"io"
 	"log"
 	"net/http"
+	"net/url"
 	"strings"
+	"time"
 
 	"github.com/ivanov-nikolay/distributed_calculator/internal/config"
 	"github.com/ivanov-nikolay/distributed_calculator/internal/models"
 )
 
-// FetchTask запрашивает задачу у оркестратора
-func FetchTask() (models.Task, error) {
+// FetchTasks запрашивает у оркестратора для агента agentID пакет из не более чем max задач с операциями
+// operations в режиме длинного опроса: оркестратор отвечает, как только появится хотя бы одна задача,
+// но не позже чем через wait. Если задачи не появились, возвращает пустой пакет
+func FetchTasks(agentID string, operations []string, max int, wait time.Duration) ([]models.Task, error) {
 	port := config.LoadServerPort()
 
-	resp, err := http.Get("http://localhost" + port.ServerPort + "/internal/task")
+	resp, err := http.Get(fmt.Sprintf("http://localhost%s/internal/task?agent_id=%s&operations=%s&max=%d&wait_ms=%d",
+		port.ServerPort, url.QueryEscape(agentID), url.QueryEscape(strings.Join(operations, ",")), max,
+		wait.Milliseconds()))
 	if err != nil {
-		return models.Task{}, fmt.Errorf("error fetching task: %v", err)
+		return nil, fmt.Errorf("error fetching tasks: %v", err)
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
 	if resp.StatusCode != http.StatusOK {
-		return models.Task{}, fmt.Errorf("status code: %d", resp.StatusCode)
+		return nil, fmt.Errorf("status code: %d", resp.StatusCode)
 	}
 
-	var received models.TaskReceived
+	var received models.TasksReceived
 
 	if err := json.NewDecoder(resp.Body).Decode(&received); err != nil {
-		return models.Task{}, fmt.Errorf("error decoding task: %v", err)
+		return nil, fmt.Errorf("error decoding tasks: %v", err)
 	}
 
-	log.Printf("received task: %+v", received.Task)
-	return received.Task, nil
+	log.Printf("received %d tasks", len(received.Tasks))
+	return received.Tasks, nil
 }
 
-// SendResult отправляет оркестратору результат вычисления задачи
-func SendResult(taskID string, result float64) error {
+// SendResults отправляет оркестратору пакет результатов вычисления задач; результаты, которые
+// оркестратор не принял, записываются в журнал
+func SendResults(results []models.TaskResult) error {
 	port := config.LoadServerPort()
 
-	data := models.TaskResult{
-		ID:     taskID,
-		Result: result,
-	}
-	jsonData, err := json.Marshal(data)
+	jsonData, err := json.Marshal(map[string][]models.TaskResult{"results": results})
 	if err != nil {
 		return err
 	}
 
 	resp, err := http.Post("http://localhost"+port.ServerPort+"/internal/task",
 		"application/json",
-		io.NopCloser(strings.NewReader(string(jsonData))))
+		bytes.NewReader(jsonData))
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("error send result, status code: %d", resp.StatusCode)
+		return fmt.Errorf("error send results, status code: %d", resp.StatusCode)
+	}
+
+	var statuses map[string][]models.TaskResultStatus
+	if err := json.NewDecoder(resp.Body).Decode(&statuses); err != nil {
+		return fmt.Errorf("error decoding result statuses: %v", err)
+	}
+	for _, status := range statuses["results"] {
+		if status.Status != http.StatusOK {
+			log.Printf("result of task %s rejected, status code: %d", status.ID, status.Status)
+		}
+	}
+
+	return nil
+}
+
+// Register регистрирует агента у оркестратора
+func Register(a models.Agent) error {
+	port := config.LoadServerPort()
+
+	jsonData, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+
+	resp, err := http.Post("http://localhost"+port.ServerPort+"/internal/agents",
+		"application/json",
+		bytes.NewReader(jsonData))
+	if err != nil {
+		return err
 	}
+	defer resp.Body.Close()
 
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return fmt.Errorf("error registering agent, status code: %d", resp.StatusCode)
+	}
 	return nil
 }
+
+// Heartbeat отправляет оркестратору сигнал активности агента; возвращает false,
+// если оркестратор не знает агента (например, после перезапуска) и агенту нужно зарегистрироваться заново
+func Heartbeat(agentID string) (bool, error) {
+	port := config.LoadServerPort()
+
+	resp, err := http.Post(fmt.Sprintf("http://localhost%s/internal/agents/%s/heartbeat",
+		port.ServerPort, url.PathEscape(agentID)), "application/json", nil)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, fmt.Errorf("error sending heartbeat, status code: %d", resp.StatusCode)
+	}
+}
@@ -1,25 +1,131 @@
 package calculator
 
 import (
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
 	"time"
 
 	"github.com/ivanov-nikolay/distributed_calculator/internal/models"
 )
 
-// ComputeTask реализует простейший математический калькулятор
-func ComputeTask(task models.Task) float64 {
+var (
+	// ErrDivisionByZero делитель операции "/", "//" или "%" равен нулю
+	ErrDivisionByZero = errors.New("division by zero")
+	// ErrNonFinite результат операции является бесконечностью или NaN
+	ErrNonFinite = errors.New("result is not a finite number")
+)
+
+// IsDeterministic проверяет, что ошибка определяется только аргументами задачи
+// и повторится при выполнении задачи любым агентом
+func IsDeterministic(err error) bool {
+	return errors.Is(err, ErrDivisionByZero) || errors.Is(err, ErrNonFinite)
+}
+
+// ComputeTask реализует простейший математический калькулятор.
+// Результат всегда вычисляется по правилам IEEE 754; если он не является конечным числом,
+// дополнительно возвращается ошибка, описывающая причину
+func ComputeTask(task models.Task) (float64, error) {
 	time.AfterFunc(time.Duration(task.OperationTime)*time.Millisecond, func() {})
 
+	result, err := compute(task)
+	if err == nil && (math.IsInf(result, 0) || math.IsNaN(result)) {
+		err = ErrNonFinite
+	}
+	return result, err
+}
+
+// compute выполняет операцию задачи
+func compute(task models.Task) (float64, error) {
+	arg1, arg2 := float64(task.Arg1), float64(task.Arg2)
+
 	switch task.Operation {
 	case "+":
-		return task.Arg1 + task.Arg2
+		return arg1 + arg2, nil
 	case "-":
-		return task.Arg1 - task.Arg2
+		return arg1 - arg2, nil
 	case "*":
-		return task.Arg1 * task.Arg2
+		return arg1 * arg2, nil
 	case "/":
-		return task.Arg1 / task.Arg2
+		return arg1 / arg2, checkDivisor(arg2)
+	case "%":
+		return floorMod(arg1, arg2), checkDivisor(arg2)
+	case "//":
+		return math.Floor(arg1 / arg2), checkDivisor(arg2)
+	case "^":
+		return math.Pow(arg1, arg2), nil
+	case "neg":
+		return -arg1, nil
+	case models.OperationCall:
+		fn, exists := functions[task.Function]
+		if !exists {
+			return 0, fmt.Errorf("unknown function %q", task.Function)
+		}
+		args := make([]float64, 0, len(task.Args))
+		for _, arg := range task.Args {
+			args = append(args, float64(arg))
+		}
+		return fn(args), nil
 	default:
-		return 0
+		return 0, fmt.Errorf("unknown operation %q", task.Operation)
+	}
+}
+
+// checkDivisor возвращает ErrDivisionByZero для нулевого делителя
+func checkDivisor(divisor float64) error {
+	if divisor == 0 {
+		return ErrDivisionByZero
+	}
+	return nil
+}
+
+// operations операции, которые вычисляет ComputeTask, кроме вызовов встроенных функций
+var operations = []string{"+", "-", "*", "/", "%", "//", "^", "neg"}
+
+// Capabilities возвращает операции и имена встроенных функций, которые вычисляет ComputeTask;
+// агент сообщает их оркестратору, чтобы получать только задачи, которые может вычислить
+func Capabilities() []string {
+	return append(slices.Clone(operations), slices.Sorted(maps.Keys(functions))...)
+}
+
+// functions реализации встроенных функций; количество аргументов проверяется оркестратором
+var functions = map[string]func(args []float64) float64{
+	"sqrt": func(args []float64) float64 { return math.Sqrt(args[0]) },
+	"abs":  func(args []float64) float64 { return math.Abs(args[0]) },
+	"sin":  func(args []float64) float64 { return math.Sin(args[0]) },
+	"cos":  func(args []float64) float64 { return math.Cos(args[0]) },
+	"log": func(args []float64) float64 {
+		if len(args) == 2 {
+			return math.Log(args[0]) / math.Log(args[1])
+		}
+		return math.Log(args[0])
+	},
+	"min": func(args []float64) float64 {
+		result := args[0]
+		for _, arg := range args[1:] {
+			result = math.Min(result, arg)
+		}
+		return result
+	},
+	"max": func(args []float64) float64 {
+		result := args[0]
+		for _, arg := range args[1:] {
+			result = math.Max(result, arg)
+		}
+		return result
+	},
+}
+
+// floorMod вычисляет остаток от деления с округлением частного вниз:
+// результат имеет знак делителя, так что a == math.Floor(a/b)*b + floorMod(a, b).
+// При нулевом делителе, как и обычное деление, следует IEEE 754: результат NaN
+// (ComputeTask при этом возвращает ErrDivisionByZero)
+func floorMod(a, b float64) float64 {
+	r := math.Mod(a, b)
+	if r != 0 && (r < 0) != (b < 0) {
+		r += b
 	}
+	return r
 }
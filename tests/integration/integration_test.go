@@ -97,8 +97,8 @@ func TestOrchestratorIntegration(t *testing.T) {
 						t.Fatalf("expected result to be a float64, but got: %T", resultValue)
 					}
 
-					if resultFloat != 0.644737 {
-						t.Fatalf("expected result 0.644737, got %v", resultFloat)
+					if resultFloat != 49.0/76 {
+						t.Fatalf("expected result %v, got %v", 49.0/76, resultFloat)
 					}
 
 					found = true
@@ -0,0 +1,139 @@
+package unit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ivanov-nikolay/distributed_calculator/internal/api/http/orchestrator"
+	"github.com/ivanov-nikolay/distributed_calculator/internal/models"
+	"github.com/ivanov-nikolay/distributed_calculator/internal/queue"
+	"github.com/stretchr/testify/assert"
+)
+
+// newAgentID возвращает ID агента, не зарегистрированного в предыдущих запусках теста
+func newAgentID(prefix string) string {
+	return fmt.Sprintf("%s-%d", prefix, time.Now().UnixNano())
+}
+
+// register регистрирует агента и возвращает код ответа
+func register(agent models.Agent) int {
+	body, _ := json.Marshal(agent)
+	w := httptest.NewRecorder()
+	orchestrator.HandleAgents(w, httptest.NewRequest(http.MethodPost, "/internal/agents", bytes.NewReader(body)))
+	return w.Code
+}
+
+// heartbeat отправляет сигнал активности агента и возвращает код ответа
+func heartbeat(id string) int {
+	w := httptest.NewRecorder()
+	orchestrator.HandleAgentHeartbeat(w, httptest.NewRequest(http.MethodPost, "/internal/agents/"+id+"/heartbeat", nil))
+	return w.Code
+}
+
+// listAgents возвращает зарегистрированных агентов по их ID
+func listAgents(t *testing.T) map[string]models.Agent {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	orchestrator.HandleAgents(w, httptest.NewRequest(http.MethodGet, "/internal/agents", nil))
+	var resp map[string][]models.Agent
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response body: %v", err)
+	}
+	list := make(map[string]models.Agent)
+	for _, agent := range resp["agents"] {
+		list[agent.ID] = agent
+	}
+	return list
+}
+
+func TestAgentRegistration(t *testing.T) {
+	orchestrator.SetTaskQueue(queue.NewFIFO())
+
+	worker := newAgentID("worker")
+	agent := models.Agent{ID: worker, Hostname: "host-1", Version: "1.0.0", ComputingPower: 4}
+	assert.Equal(t, http.StatusCreated, register(agent))
+	registered := listAgents(t)[worker]
+	assert.Equal(t, "host-1", registered.Hostname)
+	assert.Equal(t, "1.0.0", registered.Version)
+	assert.Equal(t, 4, registered.ComputingPower)
+	assert.True(t, registered.Alive)
+
+	// задачи в работе и выполненные задачи учитываются по агенту
+	id := submit(t, map[string]any{"expression": "13001 + 1"})
+	task, ok := fetchTask(t, worker, "", 13001)
+	if !ok {
+		t.Fatal("task was not published")
+	}
+	assert.Equal(t, 1, listAgents(t)[worker].InFlight)
+	assert.Equal(t, http.StatusOK, sendResult(models.TaskResult{ID: task.ID, AgentID: worker, Result: 13002}))
+	assert.Equal(t, models.Float(13002), waitExpression(t, id).Result)
+	registered = listAgents(t)[worker]
+	assert.Equal(t, 0, registered.InFlight)
+	assert.Equal(t, 1, registered.Completed)
+
+	// повторная регистрация обновляет описание агента и сохраняет статистику
+	agent.Version = "1.1.0"
+	assert.Equal(t, http.StatusOK, register(agent))
+	registered = listAgents(t)[worker]
+	assert.Equal(t, "1.1.0", registered.Version)
+	assert.Equal(t, 1, registered.Completed)
+
+	assert.Equal(t, http.StatusOK, heartbeat(worker))
+	assert.False(t, listAgents(t)[worker].LastHeartbeat.Before(registered.LastHeartbeat))
+	assert.Equal(t, http.StatusNotFound, heartbeat("worker-unknown"))
+}
+
+func TestInvalidAgentRegistration(t *testing.T) {
+	tests := []models.Agent{
+		{Hostname: "host-1", ComputingPower: 1},
+		{ID: "worker/1", ComputingPower: 1},
+		{ID: "worker-2", ComputingPower: 0},
+	}
+
+	for _, agent := range tests {
+		assert.Equal(t, http.StatusUnprocessableEntity, register(agent), agent.ID)
+	}
+}
+
+func TestMissedHeartbeatReclaimsLeases(t *testing.T) {
+	orchestrator.SetTaskQueue(queue.NewFIFO())
+	orchestrator.SetRetryPolicy(3, 0)
+	defer orchestrator.SetRetryPolicy(3, time.Second)
+
+	lost := newAgentID("worker-lost")
+	assert.Equal(t, http.StatusCreated, register(models.Agent{ID: lost, ComputingPower: 1}))
+	id := submit(t, map[string]any{"expression": "13003 + 1"})
+	task, ok := fetchTask(t, lost, "", 13003)
+	if !ok {
+		t.Fatal("task was not published")
+	}
+
+	// пока агент отправляет сигналы активности, его задачи не возвращаются в очередь
+	assert.Equal(t, 0, orchestrator.ReapLostAgents(time.Now()))
+
+	// после пропуска сигналов агент недоступен, а его задача выдается другому агенту
+	assert.GreaterOrEqual(t, orchestrator.ReapLostAgents(time.Now().Add(time.Hour)), 1)
+	redelivered, _ := fetchTask(t, "worker-2", "", 13003)
+	assert.Equal(t, task.ID, redelivered.ID)
+	assert.Equal(t, http.StatusForbidden, sendResult(models.TaskResult{ID: task.ID, AgentID: lost, Result: 13004}))
+	assert.Equal(t, http.StatusOK, sendResult(models.TaskResult{ID: task.ID, AgentID: "worker-2", Result: 13004}))
+	assert.Equal(t, models.Float(13004), waitExpression(t, id).Result)
+	assert.Equal(t, 0, listAgents(t)[lost].InFlight)
+}
+
+func TestAgentsPage(t *testing.T) {
+	worker := newAgentID("worker-page")
+	assert.Equal(t, http.StatusCreated, register(models.Agent{ID: worker, Hostname: "host-page", ComputingPower: 2}))
+
+	w := httptest.NewRecorder()
+	orchestrator.HandleAgentsPage(w, httptest.NewRequest(http.MethodGet, "/admin/agents", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "text/html")
+	assert.Contains(t, w.Body.String(), "<td>"+worker+"</td><td>host-page</td>")
+}
@@ -0,0 +1,100 @@
+package unit
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime/metrics"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/ivanov-nikolay/distributed_calculator/internal/api/http/orchestrator"
+	"github.com/ivanov-nikolay/distributed_calculator/internal/models"
+)
+
+// cpuSeconds возвращает процессорное время, затраченное на выполнение кода Go (оценка среды исполнения)
+func cpuSeconds() float64 {
+	sample := []metrics.Sample{{Name: "/cpu/classes/user:cpu-seconds"}}
+	metrics.Read(sample)
+	return sample[0].Value.Float64()
+}
+
+// percentile возвращает перцентиль p (от 0 до 1) отсортированных значений sorted
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	return sorted[int(p*float64(len(sorted)-1))]
+}
+
+// BenchmarkConcurrentExpressions отправляет тысячи выражений одновременно и ожидает их вычисления;
+// каждое выражение — цепочка из четырех зависимых задач, поэтому задержка передачи результата
+// от агента к выражению складывается четыре раза. Задержка выражения — время от его создания
+// до завершения по временам переходов статусов, поэтому она не зависит от частоты опроса статуса.
+// Отчет содержит перцентили задержки выражений (p50-ms, p95-ms, p99-ms) и процессорное время
+// вычисления одной пачки (cpu-ms)
+func BenchmarkConcurrentExpressions(b *testing.B) {
+	const (
+		expressionCount = 2000
+		agentCount      = 8
+	)
+
+	stop := make(chan struct{})
+	defer close(stop)
+	for i := 0; i < agentCount; i++ {
+		go runAgent(stop)
+	}
+
+	body, _ := json.Marshal(map[string]string{"expression": "1 + 2 + 3 + 4 + 5"})
+
+	var (
+		latencies []float64
+		cpu       float64
+	)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		startCPU := cpuSeconds()
+
+		ids := make([]string, 0, expressionCount)
+		for j := 0; j < expressionCount; j++ {
+			w := httptest.NewRecorder()
+			orchestrator.HandleCalculate(w, httptest.NewRequest(http.MethodPost, "/api/v1/calculate", bytes.NewReader(body)))
+			var created map[string]string
+			if err := json.NewDecoder(w.Body).Decode(&created); err != nil {
+				b.Fatalf("failed to decode response body: %v", err)
+			}
+			ids = append(ids, created["id"])
+		}
+
+		for len(ids) > 0 {
+			w := httptest.NewRecorder()
+			orchestrator.HandleGetExpressionByID(w, httptest.NewRequest(http.MethodGet, "/api/v1/expressions/"+ids[0], nil))
+			var resp map[string]models.Expression
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				b.Fatalf("failed to decode response body: %v", err)
+			}
+			expr := resp["expression"]
+			if !models.IsTerminal(expr.Status) {
+				time.Sleep(time.Millisecond)
+				continue
+			}
+			if expr.Status != models.StatusExpressionCompleted {
+				b.Fatalf("expression %s is %s: %s", expr.ID, expr.Status, expr.Reason)
+			}
+			created, finished := expr.Transitions[0].At, expr.Transitions[len(expr.Transitions)-1].At
+			latencies = append(latencies, float64(finished.Sub(created))/float64(time.Millisecond))
+			ids = ids[1:]
+		}
+
+		cpu += (cpuSeconds() - startCPU) * 1000
+	}
+	b.StopTimer()
+
+	sort.Float64s(latencies)
+	b.ReportMetric(percentile(latencies, 0.50), "p50-ms")
+	b.ReportMetric(percentile(latencies, 0.95), "p95-ms")
+	b.ReportMetric(percentile(latencies, 0.99), "p99-ms")
+	b.ReportMetric(cpu/float64(b.N), "cpu-ms")
+}
@@ -1,7 +1,9 @@
 package unit
 
 import (
+	"encoding/json"
 	"fmt"
+	"math"
 	"testing"
 
 	"github.com/ivanov-nikolay/distributed_calculator/internal/models"
@@ -14,16 +16,142 @@ func TestComputeTask(t *testing.T) {
 		task     models.Task
 		expected float64
 	}{
-		{models.Task{"1", 2, 3, "+", 0}, 5},
-		{models.Task{"2", 5, 2, "-", 0}, 3},
-		{models.Task{"3", 4, 3, "*", 0}, 12},
-		{models.Task{"4", 10, 2, "/", 0}, 5},
+		{models.Task{ID: "1", Arg1: 2, Arg2: 3, Operation: "+"}, 5},
+		{models.Task{ID: "2", Arg1: 5, Arg2: 2, Operation: "-"}, 3},
+		{models.Task{ID: "3", Arg1: 4, Arg2: 3, Operation: "*"}, 12},
+		{models.Task{ID: "4", Arg1: 10, Arg2: 2, Operation: "/"}, 5},
+		{models.Task{ID: "5", Arg1: 7, Arg2: 0, Operation: "neg"}, -7},
+		{models.Task{ID: "6", Arg1: -7, Arg2: 0, Operation: "neg"}, 7},
+		{models.Task{ID: "7", Arg1: 2, Arg2: 10, Operation: "^"}, 1024},
+		{models.Task{ID: "8", Arg1: 4, Arg2: 0.5, Operation: "^"}, 2},
+		{models.Task{ID: "9", Arg1: 2, Arg2: -1, Operation: "^"}, 0.5},
+		{models.Task{ID: "10", Arg1: 7, Arg2: 3, Operation: "%"}, 1},
+		{models.Task{ID: "11", Arg1: -7, Arg2: 2, Operation: "%"}, 1},
+		{models.Task{ID: "12", Arg1: 7, Arg2: -2, Operation: "%"}, -1},
+		{models.Task{ID: "13", Arg1: -7, Arg2: -2, Operation: "%"}, -1},
+		{models.Task{ID: "14", Arg1: 7.5, Arg2: 2, Operation: "%"}, 1.5},
+		{models.Task{ID: "15", Arg1: 7, Arg2: 2, Operation: "//"}, 3},
+		{models.Task{ID: "16", Arg1: -7, Arg2: 2, Operation: "//"}, -4},
+		{models.Task{ID: "17", Arg1: 7, Arg2: -2, Operation: "//"}, -4},
+		{models.Task{ID: "18", Arg1: -7, Arg2: -2, Operation: "//"}, 3},
 	}
 
 	for _, tt := range tests {
 		t.Run(fmt.Sprintf("%0.2f %s %0.2f", tt.task.Arg1, tt.task.Operation, tt.task.Arg2), func(t *testing.T) {
-			result := calculator.ComputeTask(tt.task)
+			result, err := calculator.ComputeTask(tt.task)
+			assert.NoError(t, err)
 			assert.Equal(t, tt.expected, result)
 		})
 	}
 }
+
+func TestComputeTaskFunctions(t *testing.T) {
+	tests := []struct {
+		function string
+		args     []float64
+		expected float64
+	}{
+		{"sqrt", []float64{16}, 4},
+		{"abs", []float64{-2.5}, 2.5},
+		{"sin", []float64{0}, 0},
+		{"cos", []float64{0}, 1},
+		{"log", []float64{1}, 0},
+		{"log", []float64{8, 2}, 3},
+		{"min", []float64{3, -1, 2}, -1},
+		{"max", []float64{1, 2, 3}, 3},
+		{"max", []float64{7}, 7},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s%v", tt.function, tt.args), func(t *testing.T) {
+			result, err := calculator.ComputeTask(models.Task{
+				Operation: models.OperationCall,
+				Function:  tt.function,
+				Args:      models.Floats(tt.args),
+			})
+			assert.NoError(t, err)
+			assert.InDelta(t, tt.expected, result, 1e-12)
+		})
+	}
+}
+
+func TestComputeTaskZeroDivisor(t *testing.T) {
+	tests := []struct {
+		task  models.Task
+		check func(float64) bool
+	}{
+		{models.Task{Arg1: 1, Arg2: 0, Operation: "/"}, func(v float64) bool { return math.IsInf(v, 1) }},
+		{models.Task{Arg1: 0, Arg2: 0, Operation: "/"}, math.IsNaN},
+		{models.Task{Arg1: 7, Arg2: 0, Operation: "//"}, func(v float64) bool { return math.IsInf(v, 1) }},
+		{models.Task{Arg1: -7, Arg2: 0, Operation: "//"}, func(v float64) bool { return math.IsInf(v, -1) }},
+		{models.Task{Arg1: 7, Arg2: 0, Operation: "%"}, math.IsNaN},
+		{models.Task{Arg1: 0, Arg2: 0, Operation: "//"}, math.IsNaN},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%v %s %v", tt.task.Arg1, tt.task.Operation, tt.task.Arg2), func(t *testing.T) {
+			result, err := calculator.ComputeTask(tt.task)
+			assert.ErrorIs(t, err, calculator.ErrDivisionByZero)
+			assert.True(t, calculator.IsDeterministic(err))
+			assert.True(t, tt.check(result), "unexpected result %v", result)
+		})
+	}
+}
+
+func TestComputeTaskNonFinite(t *testing.T) {
+	tests := []models.Task{
+		{Arg1: 1e308, Arg2: 10, Operation: "*"},
+		{Arg1: 0, Arg2: -1, Operation: "^"},
+		{Operation: models.OperationCall, Function: "sqrt", Args: []models.Float{-1}},
+		{Operation: models.OperationCall, Function: "log", Args: []models.Float{0}},
+		{Arg1: models.Float(math.Inf(1)), Arg2: models.Float(math.Inf(1)), Operation: "-"},
+	}
+
+	for _, task := range tests {
+		t.Run(fmt.Sprintf("%v %s %v %s%v", task.Arg1, task.Operation, task.Arg2, task.Function, task.Args), func(t *testing.T) {
+			_, err := calculator.ComputeTask(task)
+			assert.ErrorIs(t, err, calculator.ErrNonFinite)
+		})
+	}
+}
+
+func TestComputeTaskUnknownOperation(t *testing.T) {
+	_, err := calculator.ComputeTask(models.Task{Arg1: 1, Arg2: 2, Operation: "&"})
+	assert.Error(t, err)
+	assert.False(t, calculator.IsDeterministic(err))
+
+	_, err = calculator.ComputeTask(models.Task{Operation: models.OperationCall, Function: "foo", Args: []models.Float{1}})
+	assert.Error(t, err)
+}
+
+func TestFloatJSON(t *testing.T) {
+	tests := []struct {
+		value   models.Float
+		encoded string
+	}{
+		{1.5, `1.5`},
+		{-0.001, `-0.001`},
+		{models.Float(math.Inf(1)), `"+Inf"`},
+		{models.Float(math.Inf(-1)), `"-Inf"`},
+		{models.Float(math.NaN()), `"NaN"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.encoded, func(t *testing.T) {
+			data, err := json.Marshal(tt.value)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.encoded, string(data))
+
+			var decoded models.Float
+			assert.NoError(t, json.Unmarshal(data, &decoded))
+			if math.IsNaN(float64(tt.value)) {
+				assert.True(t, math.IsNaN(float64(decoded)))
+			} else {
+				assert.Equal(t, tt.value, decoded)
+			}
+		})
+	}
+
+	var decoded models.Float
+	assert.Error(t, json.Unmarshal([]byte(`"1.5"`), &decoded))
+}
@@ -0,0 +1,81 @@
+package unit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/ivanov-nikolay/distributed_calculator/internal/api/http/orchestrator"
+	"github.com/ivanov-nikolay/distributed_calculator/internal/models"
+	"github.com/ivanov-nikolay/distributed_calculator/internal/queue"
+	"github.com/stretchr/testify/assert"
+)
+
+// cancelExpression отправляет запрос на отмену вычисления выражения и возвращает код ответа
+func cancelExpression(id string) int {
+	w := httptest.NewRecorder()
+	orchestrator.HandleExpressionByID(w, httptest.NewRequest(http.MethodDelete, "/api/v1/expressions/"+id, nil))
+	return w.Code
+}
+
+func TestCancelExpression(t *testing.T) {
+	orchestrator.SetTaskQueue(queue.NewFIFO())
+	goroutines := runtime.NumGoroutine()
+
+	id := submit(t, map[string]any{"expression": "(10001 + 1) * (10002 + 1)"})
+	leased, ok := fetchTask(t, "", "", 10001)
+	if !ok {
+		t.Fatal("task was not published")
+	}
+
+	assert.Equal(t, http.StatusOK, cancelExpression(id))
+	assert.Equal(t, models.StatusExpressionCancelled, expressionStatus(t, id).Status)
+
+	// задачи выражения удалены из очереди
+	w := httptest.NewRecorder()
+	orchestrator.HandleTask(w, httptest.NewRequest(http.MethodGet, "/internal/task", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// запоздавший результат агента принимается, но не учитывается
+	assert.Equal(t, http.StatusOK, sendResult(models.TaskResult{ID: leased.ID, Result: 10002}))
+	time.Sleep(20 * time.Millisecond)
+	expr := expressionStatus(t, id)
+	assert.Equal(t, models.StatusExpressionCancelled, expr.Status)
+	assert.Equal(t, models.Float(0), expr.Result)
+	assert.Equal(t, 0, orchestrator.ReapExpiredTasks(time.Now().Add(time.Hour)))
+
+	// горутины вычисления выражения завершаются
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > goroutines && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	assert.LessOrEqual(t, runtime.NumGoroutine(), goroutines)
+
+	assert.Equal(t, http.StatusConflict, cancelExpression(id))
+}
+
+func TestCancelQueuedExpression(t *testing.T) {
+	id := submit(t, map[string]any{"expression": "10101 + 1"})
+	assert.Equal(t, http.StatusOK, cancelExpression(id))
+
+	// задачи выражения, отмененного до начала вычисления, не выдаются агентам
+	_, published := fetchTask(t, "", "", 10101)
+	assert.False(t, published)
+
+	expr := expressionStatus(t, id)
+	assert.Equal(t, models.StatusExpressionCancelled, statuses(expr)[len(expr.Transitions)-1])
+}
+
+func TestCancelCompletedExpression(t *testing.T) {
+	stop := make(chan struct{})
+	defer close(stop)
+	go runAgent(stop)
+
+	expr := calculate(t, map[string]any{"expression": "2 + 2"})
+	assert.Equal(t, http.StatusConflict, cancelExpression(expr.ID))
+	assert.Equal(t, models.StatusExpressionCompleted, expressionStatus(t, expr.ID).Status)
+
+	assert.Equal(t, http.StatusNotFound, cancelExpression("unknown"))
+}
@@ -0,0 +1,99 @@
+package unit
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ivanov-nikolay/distributed_calculator/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+// withEnvFile переходит во временный каталог с пустым файлом .env на время теста
+func withEnvFile(t *testing.T) {
+	t.Helper()
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, ".env"), nil, 0o600); err != nil {
+		t.Fatalf("failed to write .env: %v", err)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("failed to get working directory: %v", err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatalf("failed to change working directory: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = os.Chdir(wd)
+	})
+}
+
+func TestLoadConfigOrchestratorDefaults(t *testing.T) {
+	withEnvFile(t)
+
+	cfg, err := config.LoadConfigOrchestrator()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assert.Equal(t, 3, cfg.TaskMaxAttempts)
+	assert.Equal(t, 5, cfg.TaskMaxReplicas)
+	assert.Equal(t, 0, cfg.DeadLetterHoldMS)
+}
+
+func TestLoadConfigOrchestratorInvalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		err   string
+	}{
+		{"TASK_MAX_ATTEMPTS", "0", "TASK_MAX_ATTEMPTS must be between 1 and 2147483647, got 0"},
+		{"TASK_MAX_REPLICAS", "-1", "TASK_MAX_REPLICAS must be between 1 and 2147483647, got -1"},
+		{"TASK_LEASE_TIMEOUT_MS", "0", "TASK_LEASE_TIMEOUT_MS must be between 1 and 9223372036854, got 0"},
+		{"TASK_RETRY_BACKOFF_MS", "-1", "TASK_RETRY_BACKOFF_MS must be between 0 and 9223372036854, got -1"},
+		{"AGENT_HEARTBEAT_TIMEOUT_MS", "0", "AGENT_HEARTBEAT_TIMEOUT_MS must be between 1 and 9223372036854, got 0"},
+		{"TASK_ROUTING_TIMEOUT_MS", "0", "TASK_ROUTING_TIMEOUT_MS must be between 1 and 9223372036854, got 0"},
+		{"DEAD_LETTER_HOLD_MS", "-5", "DEAD_LETTER_HOLD_MS must be between 0 and 9223372036854, got -5"},
+		{"EXPRESSION_MAX_TIMEOUT_MS", "9223372036855", "EXPRESSION_MAX_TIMEOUT_MS must be between 0 and 9223372036854, got 9223372036855"},
+		{"TIME_ADDITION_MS", "-1", "TIME_ADDITION_MS must be between 0 and 9223372036854, got -1"},
+		{"TASK_QUEUE_POLICY", "random", ""},
+		{"TASK_MAX_ATTEMPTS", "three", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name+"="+test.value, func(t *testing.T) {
+			withEnvFile(t)
+			t.Setenv(test.name, test.value)
+
+			cfg, err := config.LoadConfigOrchestrator()
+			assert.Nil(t, cfg)
+			if assert.Error(t, err) && test.err != "" {
+				assert.EqualError(t, err, test.err)
+			}
+		})
+	}
+}
+
+func TestLoadConfigAgentInvalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		err   string
+	}{
+		{"AGENT_HEARTBEAT_INTERVAL_MS", "0", "AGENT_HEARTBEAT_INTERVAL_MS must be between 1 and 9223372036854, got 0"},
+		{"AGENT_HEARTBEAT_INTERVAL_MS", "-100", "AGENT_HEARTBEAT_INTERVAL_MS must be between 1 and 9223372036854, got -100"},
+		{"COMPUTING_POWER", "0", "COMPUTING_POWER must be between 1 and 2147483647, got 0"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name+"="+test.value, func(t *testing.T) {
+			withEnvFile(t)
+			t.Setenv("AGENT_ID", "agent-16001")
+			t.Setenv(test.name, test.value)
+
+			cfg, err := config.LoadConfigAgent()
+			assert.Nil(t, cfg)
+			assert.EqualError(t, err, test.err)
+		})
+	}
+}
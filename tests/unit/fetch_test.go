@@ -0,0 +1,163 @@
+package unit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ivanov-nikolay/distributed_calculator/internal/api/http/orchestrator"
+	"github.com/ivanov-nikolay/distributed_calculator/internal/models"
+	"github.com/ivanov-nikolay/distributed_calculator/internal/queue"
+	"github.com/stretchr/testify/assert"
+)
+
+// longPoll запрашивает задачу в режиме длинного опроса
+func longPoll(ctx context.Context, waitMS string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/internal/task?wait_ms="+waitMS, nil).WithContext(ctx)
+	orchestrator.HandleTask(w, r)
+	return w
+}
+
+func TestLongPollReturnsWhenTaskArrives(t *testing.T) {
+	orchestrator.SetTaskQueue(queue.NewFIFO())
+
+	responses := make(chan *httptest.ResponseRecorder)
+	start := time.Now()
+	go func() {
+		responses <- longPoll(context.Background(), "5000")
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	id := submit(t, map[string]any{"expression": "8001 + 1"})
+
+	w := <-responses
+	elapsed := time.Since(start)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.GreaterOrEqual(t, elapsed, 50*time.Millisecond)
+	assert.Less(t, elapsed, time.Second)
+
+	var received models.TaskReceived
+	if err := json.NewDecoder(w.Body).Decode(&received); err != nil {
+		t.Fatalf("failed to decode response body: %v", err)
+	}
+	assert.Equal(t, models.Float(8001), received.Task.Arg1)
+
+	assert.Equal(t, http.StatusOK, sendResult(models.TaskResult{ID: received.Task.ID, Result: 8002}))
+	assert.Equal(t, models.Float(8002), waitExpression(t, id).Result)
+}
+
+func TestLongPollTimeout(t *testing.T) {
+	orchestrator.SetTaskQueue(queue.NewFIFO())
+
+	start := time.Now()
+	w := longPoll(context.Background(), "100")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond)
+
+	// без wait_ms ответ возвращается сразу
+	start = time.Now()
+	w = httptest.NewRecorder()
+	orchestrator.HandleTask(w, httptest.NewRequest(http.MethodGet, "/internal/task", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Less(t, time.Since(start), 50*time.Millisecond)
+}
+
+func TestLongPollClientDisconnect(t *testing.T) {
+	orchestrator.SetTaskQueue(queue.NewFIFO())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	w := longPoll(ctx, "5000")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestLongPollInvalidWait(t *testing.T) {
+	for _, wait := range []string{"abc", "-1", "1.5"} {
+		w := longPoll(context.Background(), wait)
+		assert.Equal(t, http.StatusBadRequest, w.Code, wait)
+	}
+}
+
+// fetchBatch запрашивает пакет задач, ожидая появления задач до секунды
+func fetchBatch(t *testing.T, max string) []models.Task {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	orchestrator.HandleTask(w, httptest.NewRequest(http.MethodGet, "/internal/task?wait_ms=1000&max="+max, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status code %d, got %d", http.StatusOK, w.Code)
+	}
+	var received models.TasksReceived
+	if err := json.NewDecoder(w.Body).Decode(&received); err != nil {
+		t.Fatalf("failed to decode response body: %v", err)
+	}
+	return received.Tasks
+}
+
+// sendResults отправляет пакет результатов и возвращает коды их обработки
+func sendResults(t *testing.T, results []models.TaskResult) []models.TaskResultStatus {
+	t.Helper()
+
+	body, _ := json.Marshal(map[string][]models.TaskResult{"results": results})
+	w := httptest.NewRecorder()
+	orchestrator.HandleTask(w, httptest.NewRequest(http.MethodPost, "/internal/task", bytes.NewReader(body)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status code %d, got %d", http.StatusOK, w.Code)
+	}
+	var resp map[string][]models.TaskResultStatus
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response body: %v", err)
+	}
+	return resp["results"]
+}
+
+func TestBatchedFetchAndSubmit(t *testing.T) {
+	orchestrator.SetTaskQueue(queue.NewFIFO())
+
+	id := submit(t, map[string]any{"expression": "max(9001 + 1, 9002 + 1, 9003 + 1)"})
+	// задачи публикуются асинхронно; ожидаем публикации всех трех сумм
+	time.Sleep(50 * time.Millisecond)
+
+	first := fetchBatch(t, "2")
+	second := fetchBatch(t, "10")
+	assert.Len(t, first, 2)
+	assert.Len(t, second, 1)
+
+	var results []models.TaskResult
+	for _, task := range append(first, second...) {
+		results = append(results, models.TaskResult{ID: task.ID, Result: task.Arg1 + task.Arg2})
+	}
+	results = append(results, models.TaskResult{ID: "unknown", Result: 1})
+
+	statuses := sendResults(t, results)
+	assert.Len(t, statuses, 4)
+	for i, status := range statuses {
+		assert.Equal(t, results[i].ID, status.ID)
+	}
+	assert.Equal(t, []int{http.StatusOK, http.StatusOK, http.StatusOK, http.StatusNotFound},
+		[]int{statuses[0].Status, statuses[1].Status, statuses[2].Status, statuses[3].Status})
+
+	// после сумм публикуется вызов функции
+	call := fetchBatch(t, "5")
+	assert.Len(t, call, 1)
+	assert.Equal(t, models.OperationCall, call[0].Operation)
+	assert.Equal(t, []models.TaskResultStatus{{ID: call[0].ID, Status: http.StatusOK}},
+		sendResults(t, []models.TaskResult{{ID: call[0].ID, Result: 9004}}))
+	assert.Equal(t, models.Float(9004), waitExpression(t, id).Result)
+}
+
+func TestBatchedFetchInvalidMax(t *testing.T) {
+	for _, max := range []string{"", "0", "-1", "abc"} {
+		w := httptest.NewRecorder()
+		orchestrator.HandleTask(w, httptest.NewRequest(http.MethodGet, "/internal/task?max="+max, nil))
+		assert.Equal(t, http.StatusBadRequest, w.Code, max)
+	}
+}
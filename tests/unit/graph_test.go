@@ -0,0 +1,97 @@
+package unit
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ivanov-nikolay/distributed_calculator/internal/models"
+	"github.com/ivanov-nikolay/distributed_calculator/internal/service"
+	"github.com/ivanov-nikolay/distributed_calculator/pkg/calculator"
+	"github.com/stretchr/testify/assert"
+)
+
+// concurrencyProbe функция вычисления задач, измеряющая наибольшее число одновременно выполняемых задач
+type concurrencyProbe struct {
+	mu      sync.Mutex
+	running int
+	max     int
+	delay   time.Duration
+}
+
+func (p *concurrencyProbe) compute(task models.Task) (float64, error) {
+	p.mu.Lock()
+	p.running++
+	p.max = max(p.max, p.running)
+	p.mu.Unlock()
+
+	time.Sleep(p.delay)
+
+	p.mu.Lock()
+	p.running--
+	p.mu.Unlock()
+	return calculator.ComputeTask(task)
+}
+
+func TestBuildGraph(t *testing.T) {
+	node, err := service.ValidateExpression("(1 + 2) * (x - 4)", map[string]float64{"x": 3})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	graph, err := service.BuildGraph(node, map[string]float64{"x": 3})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	assert.Len(t, graph.Operations, 3)
+	assert.Equal(t, service.Operand{Source: 2}, graph.Root)
+
+	sum, difference, product := graph.Operations[0], graph.Operations[1], graph.Operations[2]
+	assert.Equal(t, "+", sum.Operation)
+	assert.Equal(t, []service.Operand{{Value: 1, Source: -1}, {Value: 2, Source: -1}}, sum.Inputs)
+	assert.Equal(t, []int{2}, sum.Dependents)
+	assert.Equal(t, []service.Operand{{Value: 3, Source: -1}, {Value: 4, Source: -1}}, difference.Inputs)
+	assert.Equal(t, []int{2}, difference.Dependents)
+	assert.Equal(t, []service.Operand{{Source: 0}, {Source: 1}}, product.Inputs)
+	assert.Empty(t, product.Dependents)
+}
+
+func TestGraphExecuteParallel(t *testing.T) {
+	tests := []struct {
+		expression  string
+		expected    float64
+		parallelism int
+	}{
+		{"42", 42, 0},
+		{"1 + 2 + 3 + 4", 10, 1},
+		{"(1 + 2) * (3 + 4)", 21, 2},
+		{"max(1 + 1, 2 * 2, 3 - 3, -sqrt(16))", 4, 4},
+		{"((1 + 2) * (3 + 4)) - ((5 + 6) * (7 + 8))", -144, 4},
+	}
+
+	for _, test := range tests {
+		t.Run(test.expression, func(t *testing.T) {
+			node, err := service.ValidateExpression(test.expression, nil)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			probe := &concurrencyProbe{delay: 50 * time.Millisecond}
+			result, err := service.Evaluate(node, nil, probe.compute)
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, result)
+			assert.Equal(t, test.parallelism, probe.max)
+		})
+	}
+}
+
+func TestGraphExecuteError(t *testing.T) {
+	node, err := service.ValidateExpression("(1 + 2) * (3 / 0) + 4", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	_, err = service.Evaluate(node, nil, calculator.ComputeTask)
+	assert.ErrorIs(t, err, calculator.ErrDivisionByZero)
+	assert.EqualError(t, err, "division by zero at position 13")
+}
@@ -0,0 +1,291 @@
+package unit
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ivanov-nikolay/distributed_calculator/internal/api/http/orchestrator"
+	"github.com/ivanov-nikolay/distributed_calculator/internal/models"
+	"github.com/ivanov-nikolay/distributed_calculator/internal/queue"
+	"github.com/ivanov-nikolay/distributed_calculator/internal/service"
+	"github.com/ivanov-nikolay/distributed_calculator/pkg/calculator"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandleCalculateUnboundIdentifiers(t *testing.T) {
+	body := `{"expression": "rate * hours + pi", "variables": {"rate": 12.5}}`
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/calculate", strings.NewReader(body))
+	w := httptest.NewRecorder()
+
+	orchestrator.HandleCalculate(w, req)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(t, w.Body.String(), "unbound identifiers: hours")
+}
+
+func TestHandleCalculateBodyTooLarge(t *testing.T) {
+	body := `{"expression": "` + strings.Repeat("(", 2<<20) + `1"}`
+	w := httptest.NewRecorder()
+	orchestrator.HandleCalculate(w, httptest.NewRequest(http.MethodPost, "/api/v1/calculate", strings.NewReader(body)))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+}
+
+func TestHandleCalculateParseError(t *testing.T) {
+	tests := []struct {
+		expression string
+		expected   models.ExpressionError
+	}{
+		{"2++", models.ExpressionError{
+			Code:     service.ErrorUnexpectedEnd,
+			Message:  "unexpected end of expression",
+			Position: 3,
+			Snippet:  "2++\n   ^",
+		}},
+		{"(3", models.ExpressionError{
+			Code:     service.ErrorUnbalancedParen,
+			Message:  "unclosed '('",
+			Position: 0,
+			Snippet:  "(3\n^",
+		}},
+		{"√2 + 1", models.ExpressionError{
+			Code:     service.ErrorUnexpectedCharacter,
+			Message:  "unexpected character '√'",
+			Position: 0,
+			Snippet:  "√2 + 1\n^",
+		}},
+		{"1 + 2 + 3 + 4 + 5 + 6 + 7 + 8 + 9 + 10 + 11 + 12 + 13 $ 14", models.ExpressionError{
+			Code:     service.ErrorUnexpectedCharacter,
+			Message:  "unexpected character '$'",
+			Position: 54,
+			Snippet:  "...+ 10 + 11 + 12 + 13 $ 14\n                       ^",
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.expression, func(t *testing.T) {
+			body, _ := json.Marshal(map[string]string{"expression": test.expression})
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/calculate", bytes.NewReader(body))
+			w := httptest.NewRecorder()
+
+			orchestrator.HandleCalculate(w, req)
+
+			assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+			var resp map[string]models.ExpressionError
+			assert.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+			assert.Equal(t, test.expected, resp["error"])
+		})
+	}
+}
+
+func TestHandleCalculateInvalidVariableName(t *testing.T) {
+	body := `{"expression": "1 + 2", "variables": {"1x": 1}}`
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/calculate", strings.NewReader(body))
+	w := httptest.NewRecorder()
+
+	orchestrator.HandleCalculate(w, req)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+}
+
+// runAgent имитирует агента: забирает задачи у оркестратора и отправляет результаты, пока не закрыт stop
+func runAgent(stop <-chan struct{}) {
+	for {
+		select {
+		case <-stop:
+			return
+		default:
+		}
+
+		w := httptest.NewRecorder()
+		orchestrator.HandleTask(w, httptest.NewRequest(http.MethodGet, "/internal/task", nil))
+		if w.Code != http.StatusOK {
+			time.Sleep(time.Millisecond)
+			continue
+		}
+
+		var received models.TaskReceived
+		if err := json.NewDecoder(w.Body).Decode(&received); err != nil {
+			continue
+		}
+		value, err := calculator.ComputeTask(received.Task)
+		result := models.TaskResult{ID: received.Task.ID, Result: models.Float(value)}
+		if err != nil {
+			result.Error = err.Error()
+			result.Retryable = !calculator.IsDeterministic(err)
+		}
+		body, _ := json.Marshal(result)
+		orchestrator.HandleTask(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/internal/task", bytes.NewReader(body)))
+	}
+}
+
+// submit отправляет выражение оркестратору и возвращает его ID
+func submit(t *testing.T, request map[string]any) string {
+	t.Helper()
+
+	body, _ := json.Marshal(request)
+	w := httptest.NewRecorder()
+	orchestrator.HandleCalculate(w, httptest.NewRequest(http.MethodPost, "/api/v1/calculate", bytes.NewReader(body)))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status code %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+	var created map[string]string
+	if err := json.NewDecoder(w.Body).Decode(&created); err != nil {
+		t.Fatalf("failed to decode response body: %v", err)
+	}
+	return created["id"]
+}
+
+// expressionStatus возвращает текущее описание выражения
+func expressionStatus(t *testing.T, id string) models.Expression {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	orchestrator.HandleGetExpressionByID(w, httptest.NewRequest(http.MethodGet, "/api/v1/expressions/"+id, nil))
+	var resp map[string]models.Expression
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response body: %v", err)
+	}
+	return resp["expression"]
+}
+
+// waitExpression ожидает завершения вычисления выражения
+func waitExpression(t *testing.T, id string) models.Expression {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if expr := expressionStatus(t, id); models.IsTerminal(expr.Status) {
+			return expr
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("expression %s did not complete in time", id)
+	return models.Expression{}
+}
+
+// calculate отправляет выражение оркестратору и ожидает завершения его вычисления
+func calculate(t *testing.T, request map[string]any) models.Expression {
+	t.Helper()
+	return waitExpression(t, submit(t, request))
+}
+
+func TestExpressionEvaluation(t *testing.T) {
+	stop := make(chan struct{})
+	defer close(stop)
+	go runAgent(stop)
+
+	tests := []struct {
+		request  map[string]any
+		expected float64
+	}{
+		{map[string]any{"expression": "(5+2) *(1-8) / (1-77)"}, 49.0 / 76},
+		{map[string]any{"expression": "-(1 + 2) * 2^3^2"}, -1536},
+		{map[string]any{"expression": "sqrt(16) + max(1, -x, 3) % 2", "variables": map[string]float64{"x": -7}}, 5},
+		{map[string]any{"expression": "rate * hours + pi", "variables": map[string]float64{"rate": 12.5, "hours": 8}}, 100 + math.Pi},
+		{map[string]any{"expression": "42"}, 42},
+	}
+
+	for _, test := range tests {
+		t.Run(test.request["expression"].(string), func(t *testing.T) {
+			expr := calculate(t, test.request)
+			assert.Equal(t, models.StatusExpressionCompleted, expr.Status)
+			assert.Equal(t, test.expected, float64(expr.Result))
+		})
+	}
+}
+
+func TestExpressionEvaluationErrors(t *testing.T) {
+	stop := make(chan struct{})
+	defer close(stop)
+	go runAgent(stop)
+
+	tests := []struct {
+		expression string
+		variables  map[string]float64
+		reason     string
+	}{
+		{"1/0", nil, "division by zero at position 1"},
+		{"2 + 7 // (1 - 1)", nil, "division by zero at position 6"},
+		{"sqrt(-1)", nil, "result is not a finite number at position 0"},
+		{"1e308 * 10", nil, "result is not a finite number at position 6"},
+		// позиция указывается в символах, а не в байтах
+		{"скорость / (скорость - скорость)", map[string]float64{"скорость": 16001}, "division by zero at position 9"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.expression, func(t *testing.T) {
+			expr := calculate(t, map[string]any{"expression": test.expression, "variables": test.variables})
+			assert.Equal(t, models.StatusExpressionFailed, expr.Status)
+			assert.Equal(t, test.reason, expr.Reason)
+		})
+	}
+}
+
+func TestExpressionEvaluationAllowInfinity(t *testing.T) {
+	orchestrator.SetAllowInfinity(true)
+	defer orchestrator.SetAllowInfinity(false)
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go runAgent(stop)
+
+	expr := calculate(t, map[string]any{"expression": "-1/0 - 1"})
+	assert.Equal(t, models.StatusExpressionCompleted, expr.Status)
+	assert.True(t, math.IsInf(float64(expr.Result), -1))
+
+	// NaN не является бесконечностью и остается ошибкой
+	expr = calculate(t, map[string]any{"expression": "0/0"})
+	assert.Equal(t, models.StatusExpressionFailed, expr.Status)
+
+	w := httptest.NewRecorder()
+	orchestrator.HandleGetExpressions(w, httptest.NewRequest(http.MethodGet, "/api/v1/expressions", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"result":"-Inf"`)
+}
+
+func TestHandleTaskUnknownResult(t *testing.T) {
+	body, _ := json.Marshal(models.TaskResult{ID: "unknown", Result: 1})
+	w := httptest.NewRecorder()
+	orchestrator.HandleTask(w, httptest.NewRequest(http.MethodPost, "/internal/task", bytes.NewReader(body)))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestExpressionPriority(t *testing.T) {
+	orchestrator.SetTaskQueue(queue.NewPriority(0, time.Now, func() queue.Queue { return queue.NewFIFO() }))
+
+	low := submit(t, map[string]any{"expression": "7001 + 1"})
+	high := submit(t, map[string]any{"expression": "7002 + 1", "priority": 5})
+	assert.Equal(t, 0, expressionStatus(t, low).Priority)
+	assert.Equal(t, 5, expressionStatus(t, high).Priority)
+
+	// задачи публикуются асинхронно; ожидаем публикации обеих задач
+	time.Sleep(50 * time.Millisecond)
+
+	// задача выражения с большим приоритетом выдается первой, хотя отправлена позже
+	var fetched []models.Task
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		orchestrator.HandleTask(w, httptest.NewRequest(http.MethodGet, "/internal/task", nil))
+		var received models.TaskReceived
+		if err := json.NewDecoder(w.Body).Decode(&received); err != nil {
+			t.Fatalf("failed to decode response body: %v", err)
+		}
+		fetched = append(fetched, received.Task)
+	}
+	assert.Equal(t, models.Float(7002), fetched[0].Arg1)
+	assert.Equal(t, 5, fetched[0].Priority)
+	assert.Equal(t, high, fetched[0].ExpressionID)
+	assert.Equal(t, models.Float(7001), fetched[1].Arg1)
+	assert.Equal(t, 0, fetched[1].Priority)
+
+	for _, task := range fetched {
+		assert.Equal(t, http.StatusOK, sendResult(models.TaskResult{ID: task.ID, Result: task.Arg1 + task.Arg2}))
+	}
+	assert.Equal(t, models.Float(7003), waitExpression(t, high).Result)
+}
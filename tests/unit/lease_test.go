@@ -0,0 +1,129 @@
+package unit
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/ivanov-nikolay/distributed_calculator/internal/api/http/orchestrator"
+	"github.com/ivanov-nikolay/distributed_calculator/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// fetchTasks запрашивает в режиме длинного опроса пакет из не более чем max задач от имени агента agent,
+// поддерживающего операции operations (пустая строка — любые операции), и возвращает код ответа и задачи
+func fetchTasks(t *testing.T, agent, operations string, max int) (int, []models.Task) {
+	t.Helper()
+
+	query := url.Values{"wait_ms": {"100"}, "max": {strconv.Itoa(max)}, "agent_id": {agent}}
+	if operations != "" {
+		query.Set("operations", operations)
+	}
+	w := httptest.NewRecorder()
+	orchestrator.HandleTask(w, httptest.NewRequest(http.MethodGet, "/internal/task?"+query.Encode(), nil))
+	if w.Code != http.StatusOK {
+		return w.Code, nil
+	}
+	var received models.TasksReceived
+	if err := json.NewDecoder(w.Body).Decode(&received); err != nil {
+		t.Fatalf("failed to decode response body: %v", err)
+	}
+	return w.Code, received.Tasks
+}
+
+// fetchTask запрашивает задачи от имени агента agent, поддерживающего операции operations, пока не получит
+// задачу с первым операндом arg1; задачи, оставшиеся от других тестов, пропускаются
+func fetchTask(t *testing.T, agent, operations string, arg1 models.Float) (models.Task, bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		_, fetched := fetchTasks(t, agent, operations, 1)
+		if task, ok := findTask(fetched, arg1); ok {
+			return task, true
+		}
+	}
+	return models.Task{}, false
+}
+
+// findTask возвращает задачу с первым операндом arg1 из списка задач
+func findTask(list []models.Task, arg1 models.Float) (models.Task, bool) {
+	for _, task := range list {
+		if task.Arg1 == arg1 {
+			return task, true
+		}
+	}
+	return models.Task{}, false
+}
+
+// sendResult отправляет результат задачи оркестратору и возвращает код ответа
+func sendResult(result models.TaskResult) int {
+	body, _ := json.Marshal(result)
+	w := httptest.NewRecorder()
+	orchestrator.HandleTask(w, httptest.NewRequest(http.MethodPost, "/internal/task", bytes.NewReader(body)))
+	return w.Code
+}
+
+func TestLeasedTaskIsRedelivered(t *testing.T) {
+	orchestrator.SetRetryPolicy(3, 0)
+	defer orchestrator.SetRetryPolicy(3, time.Second)
+
+	id := submit(t, map[string]any{"expression": "1013.5 + 1"})
+
+	// агент получил задачу и аварийно завершился
+	task, ok := fetchTask(t, "", "", 1013.5)
+	if !ok {
+		t.Fatal("task was not published")
+	}
+
+	// до истечения аренды задача не выдается повторно
+	assert.Equal(t, 0, orchestrator.ReapExpiredTasks(time.Now()))
+	_, ok = fetchTask(t, "", "", 1013.5)
+	assert.False(t, ok)
+
+	// после истечения аренды задача возвращается в очередь с тем же ID
+	assert.GreaterOrEqual(t, orchestrator.ReapExpiredTasks(time.Now().Add(time.Hour)), 1)
+	redelivered, ok := fetchTask(t, "", "", 1013.5)
+	if !ok {
+		t.Fatal("task was not redelivered")
+	}
+	assert.Equal(t, task, redelivered)
+
+	assert.Equal(t, http.StatusOK, sendResult(models.TaskResult{ID: task.ID, Result: 1014.5}))
+	expr := waitExpression(t, id)
+	assert.Equal(t, models.StatusExpressionCompleted, expr.Status)
+	assert.Equal(t, models.Float(1014.5), expr.Result)
+
+	// повторная отправка того же результата принимается, отличающийся результат отклоняется
+	assert.Equal(t, http.StatusOK, sendResult(models.TaskResult{ID: task.ID, Result: 1014.5}))
+	assert.Equal(t, http.StatusConflict, sendResult(models.TaskResult{ID: task.ID, Result: -1}))
+	assert.Equal(t, models.Float(1014.5), expressionStatus(t, id).Result)
+	assert.Equal(t, 0, orchestrator.ReapExpiredTasks(time.Now().Add(time.Hour)))
+}
+
+func TestTaskReaperRecoversCrashedAgent(t *testing.T) {
+	orchestrator.SetLeaseTimeout(20 * time.Millisecond)
+	defer orchestrator.SetLeaseTimeout(30 * time.Second)
+	orchestrator.SetRetryPolicy(3, 0)
+	defer orchestrator.SetRetryPolicy(3, time.Second)
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go orchestrator.RunTaskReaper(5*time.Millisecond, stop)
+
+	id := submit(t, map[string]any{"expression": "(2013.5 + 1) * 2"})
+	if _, ok := fetchTask(t, "", "", 2013.5); !ok {
+		t.Fatal("task was not published")
+	}
+
+	// работающий агент получает задачу после истечения аренды
+	go runAgent(stop)
+	expr := waitExpression(t, id)
+	assert.Equal(t, models.StatusExpressionCompleted, expr.Status)
+	assert.Equal(t, models.Float(4029), expr.Result)
+}
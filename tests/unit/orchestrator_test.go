@@ -1,117 +1,276 @@
 package unit
 
 import (
-	"strings"
+	"errors"
+	"fmt"
 	"testing"
 
+	"github.com/ivanov-nikolay/distributed_calculator/internal/ast"
 	"github.com/ivanov-nikolay/distributed_calculator/internal/service"
 )
 
-func normalizeWhitespace(input string) string {
-	return strings.Join(strings.Fields(input), " ")
+// parseCase выражение и ожидаемая запись его дерева разбора с полной расстановкой скобок
+type parseCase struct {
+	input    string
+	expected string
 }
 
-func TestTokenizeExpression(t *testing.T) {
-	tests := []struct {
-		input    string
-		expected []string
-	}{
-		{"2 + 3 * 4", []string{"2", "+", "3", "*", "4"}},
-		{"(5 + 3) * 4", []string{"(", "5", "+", "3", ")", " ", "*", "4"}},
-		{"1 + 2", []string{"1", "+", "2"}},
-		{"(1 + 2) * (3 / 4)", []string{"(", "1", "+", "2", ")", " ", "*", " ", "(", "3", "/", "4", ")"}},
-		{"2", []string{"2"}},
-	}
-
+// runParseCases проверяет запись дерева разбора для каждого выражения
+func runParseCases(t *testing.T, tests []parseCase) {
+	t.Helper()
 	for _, test := range tests {
 		t.Run(test.input, func(t *testing.T) {
-			result := service.TokenizeExpression(test.input)
-
-			normalizedResult := make([]string, len(result))
-			for i, token := range result {
-				normalizedResult[i] = normalizeWhitespace(token)
+			node, err := service.Parse(test.input)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
 			}
-
-			normalizedExpected := make([]string, len(test.expected))
-			for i, token := range test.expected {
-				normalizedExpected[i] = normalizeWhitespace(token)
+			if node.String() != test.expected {
+				t.Errorf("expected %v, got %v", test.expected, node.String())
 			}
+		})
+	}
+}
 
-			if len(normalizedResult) != len(normalizedExpected) {
-				t.Errorf("expected %v, got %v", normalizedExpected, normalizedResult)
-				return
-			}
+func TestParse(t *testing.T) {
+	runParseCases(t, []parseCase{
+		{"2 + 3 * 4", "(2 + (3 * 4))"},
+		{"(5 + 3) * 4", "((5 + 3) * 4)"},
+		{"1 + 2", "(1 + 2)"},
+		{"(1 + 2) * (3 / 4)", "((1 + 2) * (3 / 4))"},
+		{"2", "2"},
+		{"\t2\n*\t3 ", "(2 * 3)"},
+		{"1 - 2 - 3", "((1 - 2) - 3)"},
+		{"8 / 4 / 2", "((8 / 4) / 2)"},
+		{"((2))", "2"},
+	})
+}
 
-			for i := range normalizedResult {
-				if normalizedResult[i] != normalizedExpected[i] {
-					t.Errorf("expected %v, got %v", normalizedExpected[i], normalizedResult[i])
-				}
-			}
-		})
+func TestParseUnarySigns(t *testing.T) {
+	runParseCases(t, []parseCase{
+		{"-5+3", "(-5 + 3)"},
+		{"+5", "5"},
+		{"2*-3", "(2 * -3)"},
+		{"2/+3", "(2 / 3)"},
+		{"2--3", "(2 - -3)"},
+		{"(-1+2)", "(-1 + 2)"},
+		{"(-(1+2))", "(-(1 + 2))"},
+		{"-(-(1))", "1"},
+		{"-(-(1+x))", "(-(-(1 + x)))"},
+		{"--5", "5"},
+		{"---5", "-5"},
+		{"-+-5", "5"},
+		{"2*--(3)", "(2 * 3)"},
+		{"2*-+-(3)", "(2 * 3)"},
+		{"2*---(3)", "(2 * -3)"},
+		{"2 * - 3", "(2 * -3)"},
+		{"-x*3", "((-x) * 3)"},
+		{"-2^2", "(-(2 ^ 2))"},
+		{"2^-1", "(2 ^ -1)"},
+		{"2**-3**2", "(2 ^ (-(3 ^ 2)))"},
+		{"max(-1,-x)", "max(-1, (-x))"},
+	})
+}
+
+func TestParsePower(t *testing.T) {
+	runParseCases(t, []parseCase{
+		{"2^10", "(2 ^ 10)"},
+		{"2**10", "(2 ^ 10)"},
+		{"2^3^2", "(2 ^ (3 ^ 2))"},
+		{"2^3**2", "(2 ^ (3 ^ 2))"},
+		{"(2^3)^2", "((2 ^ 3) ^ 2)"},
+		{"2*3^2", "(2 * (3 ^ 2))"},
+		{"2^3*2", "((2 ^ 3) * 2)"},
+		{"(1+2)**2*3", "(((1 + 2) ^ 2) * 3)"},
+	})
+}
+
+func TestParseMultiCharOperators(t *testing.T) {
+	runParseCases(t, []parseCase{
+		{"7%3", "(7 % 3)"},
+		{"7//2", "(7 // 2)"},
+		{"-7//-2", "(-7 // -2)"},
+		{"1+7%3", "(1 + (7 % 3))"},
+		{"7//2*3", "((7 // 2) * 3)"},
+		{"8%5//2", "((8 % 5) // 2)"},
+		{"2**3//3%2", "(((2 ^ 3) // 3) % 2)"},
+		{"(8/2)//3", "((8 / 2) // 3)"},
+	})
+}
+
+func TestParseFunctions(t *testing.T) {
+	runParseCases(t, []parseCase{
+		{"sqrt(16)+max(1,2,3)", "(sqrt(16) + max(1, 2, 3))"},
+		{"max(1+2*3,4)", "max((1 + (2 * 3)), 4)"},
+		{"max(min(1,2),(3))", "max(min(1, 2), 3)"},
+		{"-abs(-2)^2", "(-(abs(-2) ^ 2))"},
+		{"2*sqrt(4)", "(2 * sqrt(4))"},
+		{"log(8,2)^2", "(log(8, 2) ^ 2)"},
+		{"max(1)", "max(1)"},
+	})
+}
+
+func TestParseLiterals(t *testing.T) {
+	runParseCases(t, []parseCase{
+		{"1e-3+1", "(0.001 + 1)"},
+		{"1.5E+10*2", "(1.5e+10 * 2)"},
+		{"2.5e3-1", "(2500 - 1)"},
+		{"-1e-3", "-0.001"},
+		{"1_000_000", "1e+06"},
+		{"0x1F+0b101", "(31 + 5)"},
+		{"0o17", "15"},
+		{".5+2.", "(0.5 + 2)"},
+		{"rate*hours+pi", "((rate * hours) + pi)"},
+	})
+}
+
+func TestParsePositions(t *testing.T) {
+	node, err := service.Parse("max(x, -(2 * y)) + 1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var positions []int
+	ast.Walk(node, func(n ast.Node) {
+		positions = append(positions, n.Pos())
+	})
+
+	// "+" max x "-" "*" 2 y 1
+	expected := []int{17, 0, 4, 7, 11, 9, 13, 19}
+	if len(positions) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, positions)
+	}
+	for i := range positions {
+		if positions[i] != expected[i] {
+			t.Errorf("expected %v, got %v", expected, positions)
+			break
+		}
 	}
 }
 
-func TestShuntingYard(t *testing.T) {
+func TestCheckFunctionCall(t *testing.T) {
 	tests := []struct {
-		tokens   []string
-		expected []string
+		name    string
+		argc    int
+		isValid bool
 	}{
-		{[]string{"2", "+", "3", "*", "4"}, []string{"2", "3", "4", "*", "+"}},
-		{[]string{"(", "5", "+", "3", ")", "*", "4"}, []string{"5", "3", "+", "4", "*"}},
-		{[]string{"1", "+", "2"}, []string{"1", "2", "+"}},
-		{[]string{"(", "1", "+", "2", ")", "*", "(", "3", "/", "4", ")"}, []string{"1", "2", "+", "3", "4", "/", "*"}},
-		{[]string{"2"}, []string{"2"}},
+		{"sqrt", 1, true},
+		{"sqrt", 2, false},
+		{"log", 1, true},
+		{"log", 2, true},
+		{"log", 3, false},
+		{"max", 1, true},
+		{"max", 10, true},
+		{"min", 0, false},
+		{"foo", 1, false},
 	}
 
 	for _, test := range tests {
-		t.Run(strings.Join(test.tokens, " "), func(t *testing.T) {
-			result := service.ShuntingYard(test.tokens)
-			if !equal(result, test.expected) {
-				t.Errorf("expected %v, got %v", test.expected, result)
+		t.Run(fmt.Sprintf("%s/%d", test.name, test.argc), func(t *testing.T) {
+			err := service.CheckFunctionCall(test.name, test.argc)
+			if (err == nil) != test.isValid {
+				t.Errorf("expected valid=%v, got error %v", test.isValid, err)
 			}
 		})
 	}
 }
 
-func TestParseNumber(t *testing.T) {
+func TestLex(t *testing.T) {
+	tokens, err := service.Lex("max(0x10, 2.5e-1) ** x_1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []service.Token{
+		{Kind: service.TokenIdentifier, Text: "max", Pos: 0},
+		{Kind: service.TokenLeftParen, Text: "(", Pos: 3},
+		{Kind: service.TokenNumber, Text: "0x10", Literal: service.LiteralHex, Value: 16, Pos: 4},
+		{Kind: service.TokenComma, Text: ",", Pos: 8},
+		{Kind: service.TokenNumber, Text: "2.5e-1", Literal: service.LiteralScientific, Value: 0.25, Pos: 10},
+		{Kind: service.TokenRightParen, Text: ")", Pos: 16},
+		{Kind: service.TokenOperator, Text: "^", Pos: 18},
+		{Kind: service.TokenIdentifier, Text: "x_1", Pos: 21},
+	}
+	if len(tokens) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, tokens)
+	}
+	for i := range tokens {
+		if tokens[i] != expected[i] {
+			t.Errorf("expected %+v, got %+v", expected[i], tokens[i])
+		}
+	}
+}
+
+func TestLexErrors(t *testing.T) {
 	tests := []struct {
-		input    string
-		expected float64
+		input string
+		pos   int
 	}{
-		{"3.14", 3.14},
-		{"2", 2.0},
-		{"0", 0.0},
-		{"-1", -1.0},
-		{"3.14159", 3.14159},
+		{"2 $ 3", 2},
+		{"1__0", 1},
+		{"_1 + 1_", 6},
+		{"1_.5", 1},
+		{"0x", 0},
+		{"0b102", 4},
+		{"0o8", 2},
+		{"1e", 2},
+		{"1e+", 3},
+		{"2x", 1},
+		{"1.2.3", 3},
+		{"1e400", 0},
 	}
 
 	for _, test := range tests {
 		t.Run(test.input, func(t *testing.T) {
-			result := service.ParseNumber(test.input)
-			if result != test.expected {
-				t.Errorf("expected %v, got %v", test.expected, result)
+			_, err := service.Lex(test.input)
+
+			var parseErr *service.ParseError
+			if !errors.As(err, &parseErr) {
+				t.Fatalf("expected ParseError, got %v", err)
+			}
+			if parseErr.Pos != test.pos {
+				t.Errorf("expected position %d, got %d (%v)", test.pos, parseErr.Pos, parseErr)
 			}
 		})
 	}
 }
 
-func TestIsNumber(t *testing.T) {
+func TestLexLiteral(t *testing.T) {
 	tests := []struct {
-		token    string
-		expected bool
+		input    string
+		expected float64
+		kind     service.LiteralKind
 	}{
-		{"3.14", true},
-		{"2", true},
-		{"-1", true},
-		{"abc", false},
-		{"", false},
+		{"3.14", 3.14, service.LiteralDecimal},
+		{"2", 2.0, service.LiteralInteger},
+		{"0", 0.0, service.LiteralInteger},
+		{"3.14159", 3.14159, service.LiteralDecimal},
+		{"1e-3", 0.001, service.LiteralScientific},
+		{"1.5E+10", 1.5e10, service.LiteralScientific},
+		{"1_000", 1000, service.LiteralInteger},
+		{"0xFF", 255, service.LiteralHex},
+		{"0b1010", 10, service.LiteralBinary},
+		{"0o17", 15, service.LiteralOctal},
 	}
 
 	for _, test := range tests {
-		t.Run(test.token, func(t *testing.T) {
-			result := service.IsNumber(test.token)
-			if result != test.expected {
-				t.Errorf("expected %v, got %v", test.expected, result)
+		t.Run(test.input, func(t *testing.T) {
+			tokens, err := service.Lex(test.input)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(tokens) != 1 || tokens[0].Kind != service.TokenNumber {
+				t.Fatalf("expected a single number token, got %+v", tokens)
+			}
+			if tokens[0].Value != test.expected || tokens[0].Literal != test.kind {
+				t.Errorf("expected %v (%v), got %v (%v)", test.expected, test.kind, tokens[0].Value, tokens[0].Literal)
+			}
+		})
+	}
+
+	for _, input := range []string{"1e", "1e+", "2x", "0x", "0b102", "1__0", "1_"} {
+		t.Run("invalid "+input, func(t *testing.T) {
+			if _, err := service.Lex(input); err == nil {
+				t.Errorf("expected error for %q", input)
 			}
 		})
 	}
@@ -126,6 +285,9 @@ func TestIsOperator(t *testing.T) {
 		{"-", true},
 		{"*", true},
 		{"/", true},
+		{"^", true},
+		{"%", true},
+		{"//", true},
 		{"2", false},
 		{"abc", false},
 	}
@@ -139,15 +301,3 @@ func TestIsOperator(t *testing.T) {
 		})
 	}
 }
-
-func equal(a, b []string) bool {
-	if len(a) != len(b) {
-		return false
-	}
-	for i := range a {
-		if a[i] != b[i] {
-			return false
-		}
-	}
-	return true
-}
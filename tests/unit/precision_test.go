@@ -0,0 +1,93 @@
+package unit
+
+import (
+	"math"
+	"testing"
+
+	"github.com/ivanov-nikolay/distributed_calculator/internal/models"
+	"github.com/ivanov-nikolay/distributed_calculator/internal/service"
+	"github.com/ivanov-nikolay/distributed_calculator/pkg/calculator"
+)
+
+// precisionCases выражения, результат которых искажается при округлении промежуточных результатов
+var precisionCases = []struct {
+	expression string
+	variables  map[string]float64
+}{
+	{"1e-9 * 1e9", nil},
+	{"123456789 * 987654321", nil},
+	{"123456789.123 * 987654321.987 * 1e10", nil},
+	{"0.1 + 0.2", nil},
+	{"1 / 3 * 3", nil},
+	{"1 / 3 + 1 / 3 + 1 / 3", nil},
+	{"(1e-7 + 1e-8) * 1e7", nil},
+	{"1e300 * 1e-300 / 7", nil},
+	{"2^0.5 * 2^0.5", nil},
+	{"sqrt(2) * sqrt(2) - 2", nil},
+	{"0x1F_FFFF_FFFF_FFFF + 1", nil},
+	{"2^53 + 1 - 2^53", nil},
+	{"-(1e-12) * 3", nil},
+	{"log(1e-15) / 7", nil},
+	{"sin(pi) * 1e16", nil},
+	{"max(1e-20, 2e-20) * 1e20", nil},
+	{"x * y / 3", map[string]float64{"x": 0.1, "y": 1e-9}},
+	{"x % 0.3 // 1e-9", map[string]float64{"x": 10.123456789}},
+}
+
+// evaluateLocally вычисляет выражение без оркестратора и агентов
+func evaluateLocally(t *testing.T, expression string, variables map[string]float64) float64 {
+	t.Helper()
+	node, err := service.ValidateExpression(expression, variables)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	result, err := service.Evaluate(node, variables, calculator.ComputeTask)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return result
+}
+
+func TestEvaluateLocally(t *testing.T) {
+	tests := []struct {
+		expression string
+		expected   float64
+	}{
+		{"1e-9 * 1e9", 1},
+		{"123456789 * 987654321", 123456789 * 987654321},
+		{"0.1 + 0.2", 0.30000000000000004},
+		{"2^53 + 1 - 2^53", 0},
+		{"0x1F_FFFF_FFFF_FFFF + 1", 1 << 53},
+		{"0xFFFF_FFFF_FFFF_FFFF_F", 0xFFFFFFFFFFFFFFFFF},
+	}
+
+	for _, test := range tests {
+		t.Run(test.expression, func(t *testing.T) {
+			result := evaluateLocally(t, test.expression, nil)
+			if math.Float64bits(result) != math.Float64bits(test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, result)
+			}
+		})
+	}
+}
+
+func TestDistributedResultsMatchLocalEvaluation(t *testing.T) {
+	stop := make(chan struct{})
+	defer close(stop)
+	go runAgent(stop)
+
+	for _, test := range precisionCases {
+		t.Run(test.expression, func(t *testing.T) {
+			expected := evaluateLocally(t, test.expression, test.variables)
+
+			expr := calculate(t, map[string]any{"expression": test.expression, "variables": test.variables})
+			if expr.Status != models.StatusExpressionCompleted {
+				t.Fatalf("expected status %q, got %q", models.StatusExpressionCompleted, expr.Status)
+			}
+			if math.Float64bits(float64(expr.Result)) != math.Float64bits(expected) {
+				t.Errorf("expected %v (%#x), got %v (%#x)",
+					expected, math.Float64bits(expected), float64(expr.Result), math.Float64bits(float64(expr.Result)))
+			}
+		})
+	}
+}
@@ -0,0 +1,226 @@
+package unit
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/ivanov-nikolay/distributed_calculator/internal/models"
+	"github.com/ivanov-nikolay/distributed_calculator/internal/queue"
+	"github.com/stretchr/testify/assert"
+)
+
+// drain извлекает все задачи из очереди и возвращает их ID в порядке выдачи
+func drain(q queue.Queue) []string {
+	var ids []string
+	for {
+		task, ok := q.Pop()
+		if !ok {
+			return ids
+		}
+		ids = append(ids, task.ID)
+	}
+}
+
+// pushAll добавляет в очередь задачи, заданные парами "ID задачи, ID выражения"
+func pushAll(q queue.Queue, pairs ...string) {
+	for i := 0; i < len(pairs); i += 2 {
+		q.Push(models.Task{ID: pairs[i], ExpressionID: pairs[i+1]})
+	}
+}
+
+func TestFIFOOrder(t *testing.T) {
+	q := queue.NewFIFO()
+	pushAll(q, "1", "a", "2", "b", "3", "b", "4", "b", "5", "a")
+
+	// повторная постановка задачи, уже находящейся в очереди, не меняет ее места
+	q.Push(models.Task{ID: "1", ExpressionID: "a"})
+	assert.Equal(t, 5, q.Len())
+
+	assert.True(t, q.Remove("3"))
+	assert.False(t, q.Remove("3"))
+	assert.Equal(t, []string{"1", "2", "4", "5"}, drain(q))
+	assert.Equal(t, 0, q.Len())
+
+	// задача, извлеченная из очереди и возвращенная в нее, становится в конец
+	pushAll(q, "6", "a", "7", "a")
+	task, _ := q.Pop()
+	q.Push(task)
+	assert.Equal(t, []string{"7", "6"}, drain(q))
+}
+
+func TestRoundRobinOrder(t *testing.T) {
+	q := queue.NewRoundRobin()
+
+	// выражение "a" ставит в очередь одну задачу, затем выражение "b" — много задач
+	pushAll(q, "1", "a", "2", "b", "3", "b", "4", "b", "5", "b", "6", "c", "7", "a")
+	assert.Equal(t, 7, q.Len())
+
+	// выражения обслуживаются по очереди в порядке появления, задачи выражения — в порядке постановки
+	assert.Equal(t, []string{"1", "2", "6", "7", "3", "4", "5"}, drain(q))
+	assert.Equal(t, 0, q.Len())
+}
+
+func TestRoundRobinNewExpressionDoesNotStarveOld(t *testing.T) {
+	q := queue.NewRoundRobin()
+	pushAll(q, "1", "old", "2", "old")
+	for i := 0; i < 100; i++ {
+		q.Push(models.Task{ID: strconv.Itoa(100 + i), ExpressionID: "new"})
+	}
+
+	// задачи старого выражения выдаются не позже, чем через одну задачу нового
+	first, _ := q.Pop()
+	_, _ = q.Pop()
+	third, _ := q.Pop()
+	assert.Equal(t, "1", first.ID)
+	assert.Equal(t, "2", third.ID)
+}
+
+func TestRoundRobinRemove(t *testing.T) {
+	q := queue.NewRoundRobin()
+	pushAll(q, "1", "a", "2", "b", "3", "a", "4", "b")
+
+	assert.True(t, q.Remove("2"))
+	assert.True(t, q.Remove("4"))
+	assert.False(t, q.Remove("4"))
+	q.Push(models.Task{ID: "1", ExpressionID: "a"})
+	assert.Equal(t, 2, q.Len())
+
+	// выражение без задач покидает очередь обслуживания и при новой задаче становится в ее конец
+	pushAll(q, "5", "b")
+	assert.Equal(t, []string{"1", "5", "3"}, drain(q))
+}
+
+func TestNewQueue(t *testing.T) {
+	q, err := queue.New(queue.PolicyFIFO)
+	assert.NoError(t, err)
+	assert.IsType(t, &queue.FIFO{}, q)
+
+	q, err = queue.New(queue.PolicyRoundRobin)
+	assert.NoError(t, err)
+	assert.IsType(t, &queue.RoundRobin{}, q)
+
+	_, err = queue.New("random")
+	assert.Error(t, err)
+}
+
+// fakeClock управляемый источник времени
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+// newPriorityQueue создает очередь с приоритетами и очередями уровней FIFO
+func newPriorityQueue(aging time.Duration, clock *fakeClock) *queue.Priority {
+	return queue.NewPriority(aging, clock.Now, func() queue.Queue { return queue.NewFIFO() })
+}
+
+// pushPriority добавляет в очередь задачу с приоритетом
+func pushPriority(q queue.Queue, id string, priority int) {
+	q.Push(models.Task{ID: id, ExpressionID: id, Priority: priority})
+}
+
+func TestPriorityOrder(t *testing.T) {
+	q := newPriorityQueue(0, &fakeClock{now: time.Now()})
+	pushPriority(q, "1", 0)
+	pushPriority(q, "2", 5)
+	pushPriority(q, "3", 0)
+	pushPriority(q, "4", 5)
+	pushPriority(q, "5", 9)
+	pushPriority(q, "6", -1)
+
+	// повторная постановка задачи, уже находящейся в очереди, не меняет ее приоритета
+	pushPriority(q, "6", 10)
+	assert.Equal(t, 6, q.Len())
+
+	assert.True(t, q.Remove("4"))
+	assert.False(t, q.Remove("4"))
+	assert.Equal(t, []string{"5", "2", "1", "3", "6"}, drain(q))
+	assert.Equal(t, 0, q.Len())
+}
+
+func TestPriorityAging(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+	q := newPriorityQueue(time.Second, clock)
+
+	pushPriority(q, "low", 0)
+	for i := 0; i < 10; i++ {
+		pushPriority(q, "high"+strconv.Itoa(i), 2)
+	}
+
+	// без ожидания задачи с высоким приоритетом выдаются первыми
+	task, _ := q.Pop()
+	assert.Equal(t, "high0", task.ID)
+
+	// уровень с низким приоритетом ожидает 3 с и обгоняет уровень, который только что получил задачу
+	clock.now = clock.now.Add(3 * time.Second)
+	task, _ = q.Pop()
+	assert.Equal(t, "high1", task.ID)
+	task, _ = q.Pop()
+	assert.Equal(t, "low", task.ID)
+
+	task, _ = q.Pop()
+	assert.Equal(t, "high2", task.ID)
+}
+
+func TestPriorityWithRoundRobinLevels(t *testing.T) {
+	q := queue.NewPriority(0, time.Now, func() queue.Queue { return queue.NewRoundRobin() })
+	q.Push(models.Task{ID: "1", ExpressionID: "a", Priority: 1})
+	q.Push(models.Task{ID: "2", ExpressionID: "a", Priority: 1})
+	q.Push(models.Task{ID: "3", ExpressionID: "b", Priority: 1})
+	q.Push(models.Task{ID: "4", ExpressionID: "c", Priority: 0})
+
+	assert.Equal(t, []string{"1", "3", "2", "4"}, drain(q))
+}
+
+// except возвращает условие, пропускающее задачи с указанными ID
+func except(ids ...string) func(models.Task) bool {
+	return func(task models.Task) bool {
+		for _, id := range ids {
+			if task.ID == id {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+func TestFIFOPopFirst(t *testing.T) {
+	q := queue.NewFIFO()
+	pushAll(q, "1", "a", "2", "a", "3", "a")
+
+	// пропущенная задача остается в начале очереди
+	task, ok := q.PopFirst(except("1"))
+	assert.True(t, ok)
+	assert.Equal(t, "2", task.ID)
+	_, ok = q.PopFirst(except("1", "3"))
+	assert.False(t, ok)
+	assert.Equal(t, 2, q.Len())
+	assert.Equal(t, []string{"1", "3"}, drain(q))
+}
+
+func TestRoundRobinPopFirst(t *testing.T) {
+	q := queue.NewRoundRobin()
+	pushAll(q, "1", "a", "2", "b", "3", "b", "4", "c")
+
+	// выражение без подходящих задач сохраняет свое место в очереди обслуживания
+	task, _ := q.PopFirst(except("1"))
+	assert.Equal(t, "2", task.ID)
+	assert.Equal(t, []string{"1", "4", "3"}, drain(q))
+}
+
+func TestPriorityPopFirst(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+	q := newPriorityQueue(time.Second, clock)
+	pushPriority(q, "low", 0)
+	pushPriority(q, "high", 2)
+
+	// уровень, задача которого пропущена, не теряет накопленное время ожидания
+	clock.now = clock.now.Add(3 * time.Second)
+	task, _ := q.PopFirst(except("high"))
+	assert.Equal(t, "low", task.ID)
+	pushPriority(q, "low2", 0)
+	pushPriority(q, "high2", 2)
+	assert.Equal(t, []string{"high", "high2", "low2"}, drain(q))
+}
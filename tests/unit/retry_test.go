@@ -0,0 +1,222 @@
+package unit
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ivanov-nikolay/distributed_calculator/internal/api/http/orchestrator"
+	"github.com/ivanov-nikolay/distributed_calculator/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// failure результат задачи с ошибкой, которая может не повториться при повторном выполнении
+func failure(id string) models.TaskResult {
+	return models.TaskResult{ID: id, Error: "unknown operation", Retryable: true}
+}
+
+// deadLetters возвращает содержимое очереди недоставленных задач
+func deadLetters(t *testing.T) map[string]models.DeadLetter {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	orchestrator.HandleGetDeadLetters(w, httptest.NewRequest(http.MethodGet, "/internal/dead-letters", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status code %d, got %d", http.StatusOK, w.Code)
+	}
+	var resp map[string][]models.DeadLetter
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response body: %v", err)
+	}
+
+	letters := make(map[string]models.DeadLetter)
+	for _, letter := range resp["dead_letters"] {
+		letters[letter.Task.ID] = letter
+	}
+	return letters
+}
+
+// requeue возвращает задачу из очереди недоставленных задач и возвращает код ответа
+func requeue(id string) int {
+	w := httptest.NewRecorder()
+	orchestrator.HandleRequeueDeadLetter(w, httptest.NewRequest(http.MethodPost, "/internal/dead-letters/"+id+"/requeue", nil))
+	return w.Code
+}
+
+func TestRetryableFailureIsRetriedWithBackoff(t *testing.T) {
+	orchestrator.SetRetryPolicy(3, 50*time.Millisecond)
+	defer orchestrator.SetRetryPolicy(3, time.Second)
+
+	id := submit(t, map[string]any{"expression": "3014.5 + 1"})
+	task, ok := fetchTask(t, "", "", 3014.5)
+	if !ok {
+		t.Fatal("task was not published")
+	}
+
+	failedAt := time.Now()
+	assert.Equal(t, http.StatusOK, sendResult(failure(task.ID)))
+	assert.Equal(t, models.StatusExpressionRunning, expressionStatus(t, id).Status)
+
+	retried, ok := fetchTask(t, "", "", 3014.5)
+	if !ok {
+		t.Fatal("task was not retried")
+	}
+	assert.Equal(t, task.ID, retried.ID)
+	assert.GreaterOrEqual(t, time.Since(failedAt), 50*time.Millisecond)
+
+	assert.Equal(t, http.StatusOK, sendResult(models.TaskResult{ID: task.ID, Result: 3015.5}))
+	expr := waitExpression(t, id)
+	assert.Equal(t, models.StatusExpressionCompleted, expr.Status)
+	assert.Equal(t, models.Float(3015.5), expr.Result)
+}
+
+func TestDeadLetterAndRequeue(t *testing.T) {
+	orchestrator.SetRetryPolicy(2, 0)
+	defer orchestrator.SetRetryPolicy(3, time.Second)
+	orchestrator.SetDeadLetterHold(time.Minute)
+	defer orchestrator.SetDeadLetterHold(0)
+
+	id := submit(t, map[string]any{"expression": "4014.5 * 2"})
+	for attempt := 0; attempt < 2; attempt++ {
+		task, ok := fetchTask(t, "", "", 4014.5)
+		if !ok {
+			t.Fatalf("task was not published for attempt %d", attempt+1)
+		}
+		assert.Equal(t, http.StatusOK, sendResult(failure(task.ID)))
+	}
+
+	// после исчерпания попыток задача перемещается в очередь недоставленных задач, а вычисление выражения
+	// останавливается
+	expr := expressionStatus(t, id)
+	assert.Equal(t, models.StatusExpressionBlocked, expr.Status)
+	assert.True(t, strings.HasSuffix(expr.Reason, "failed after 2 attempts: unknown operation"), expr.Reason)
+
+	var letter models.DeadLetter
+	for _, l := range deadLetters(t) {
+		if l.Task.ExpressionID == id {
+			letter = l
+		}
+	}
+	assert.Equal(t, 2, letter.Attempts)
+	assert.Equal(t, "unknown operation", letter.Reason)
+	assert.Equal(t, models.Float(4014.5), letter.Task.Arg1)
+
+	// после возврата в очередь вычисление выражения продолжается
+	assert.Equal(t, http.StatusOK, requeue(letter.Task.ID))
+	assert.Equal(t, models.StatusExpressionRunning, expressionStatus(t, id).Status)
+	assert.NotContains(t, deadLetters(t), letter.Task.ID)
+
+	task, ok := fetchTask(t, "", "", 4014.5)
+	if !ok {
+		t.Fatal("task was not requeued")
+	}
+	assert.Equal(t, http.StatusOK, sendResult(models.TaskResult{ID: task.ID, Result: 8029}))
+	expr = waitExpression(t, id)
+	assert.Equal(t, models.StatusExpressionCompleted, expr.Status)
+	assert.Equal(t, models.Float(8029), expr.Result)
+
+	assert.Equal(t, http.StatusNotFound, requeue(letter.Task.ID))
+}
+
+func TestDeterministicErrorIsNotRetried(t *testing.T) {
+	id := submit(t, map[string]any{"expression": "5014.5 / 0"})
+	task, ok := fetchTask(t, "", "", 5014.5)
+	if !ok {
+		t.Fatal("task was not published")
+	}
+
+	assert.Equal(t, http.StatusOK, sendResult(models.TaskResult{ID: task.ID, Error: "division by zero"}))
+	expr := waitExpression(t, id)
+	assert.Equal(t, models.StatusExpressionFailed, expr.Status)
+	assert.Equal(t, "division by zero at position 7", expr.Reason)
+	assert.NotContains(t, deadLetters(t), task.ID)
+}
+
+// deadLetterOf возвращает задачу выражения id из очереди недоставленных задач
+func deadLetterOf(t *testing.T, id string) (models.DeadLetter, bool) {
+	t.Helper()
+
+	for _, letter := range deadLetters(t) {
+		if letter.Task.ExpressionID == id {
+			return letter, true
+		}
+	}
+	return models.DeadLetter{}, false
+}
+
+func TestDeadLetterFailsExpression(t *testing.T) {
+	orchestrator.SetRetryPolicy(1, 0)
+	defer orchestrator.SetRetryPolicy(3, time.Second)
+
+	// без времени ожидания возврата задачи вычисление выражения сразу завершается ошибкой задачи
+	id := submit(t, map[string]any{"expression": "6014.5 * 2"})
+	task, ok := fetchTask(t, "", "", 6014.5)
+	if !ok {
+		t.Fatal("task was not published")
+	}
+	assert.Equal(t, http.StatusOK, sendResult(failure(task.ID)))
+
+	expr := waitExpression(t, id)
+	assert.Equal(t, models.StatusExpressionFailed, expr.Status)
+	assert.Equal(t, "task "+task.ID+" failed after 1 attempts: unknown operation", expr.Reason)
+	_, exists := deadLetterOf(t, id)
+	assert.False(t, exists)
+	assert.Equal(t, http.StatusNotFound, requeue(task.ID))
+}
+
+func TestBlockedExpressionTimesOut(t *testing.T) {
+	orchestrator.SetRetryPolicy(1, 0)
+	defer orchestrator.SetRetryPolicy(3, time.Second)
+	orchestrator.SetDeadLetterHold(time.Minute)
+	defer orchestrator.SetDeadLetterHold(0)
+
+	id := submit(t, map[string]any{"expression": "6017.5 * 2", "timeout_ms": 300})
+	task, ok := fetchTask(t, "", "", 6017.5)
+	if !ok {
+		t.Fatal("task was not published")
+	}
+	assert.Equal(t, http.StatusOK, sendResult(failure(task.ID)))
+	assert.Equal(t, models.StatusExpressionBlocked, expressionStatus(t, id).Status)
+
+	// по истечении времени выражения его задача удаляется из очереди недоставленных задач
+	expr := waitExpression(t, id)
+	assert.Equal(t, models.StatusExpressionTimedOut, expr.Status)
+	assert.Equal(t, "expression was not evaluated within 300 ms", expr.Reason)
+	assert.Equal(t, []string{models.StatusExpressionQueued, models.StatusExpressionParsing, models.StatusExpressionRunning,
+		models.StatusExpressionBlocked, models.StatusExpressionTimedOut}, statuses(expr))
+	_, exists := deadLetterOf(t, id)
+	assert.False(t, exists)
+	assert.Equal(t, http.StatusNotFound, requeue(task.ID))
+}
+
+func TestDeadLetterHoldEndsEvaluation(t *testing.T) {
+	orchestrator.SetRetryPolicy(1, 0)
+	defer orchestrator.SetRetryPolicy(3, time.Second)
+	orchestrator.SetDeadLetterHold(10 * time.Minute)
+	defer orchestrator.SetDeadLetterHold(0)
+
+	id := submit(t, map[string]any{"expression": "7014.5 * 2"})
+	task, ok := fetchTask(t, "", "", 7014.5)
+	if !ok {
+		t.Fatal("task was not published")
+	}
+	assert.Equal(t, http.StatusOK, sendResult(failure(task.ID)))
+	assert.Equal(t, models.StatusExpressionBlocked, expressionStatus(t, id).Status)
+
+	letter, exists := deadLetterOf(t, id)
+	if !exists {
+		t.Fatal("task was not dead-lettered")
+	}
+	assert.Equal(t, 0, orchestrator.ReapDeadLetters(letter.FailedAt))
+	assert.GreaterOrEqual(t, orchestrator.ReapDeadLetters(letter.FailedAt.Add(10*time.Minute)), 1)
+
+	expr := waitExpression(t, id)
+	assert.Equal(t, models.StatusExpressionFailed, expr.Status)
+	assert.True(t, strings.HasSuffix(expr.Reason, "failed after 1 attempts: unknown operation"), expr.Reason)
+	_, exists = deadLetterOf(t, id)
+	assert.False(t, exists)
+	assert.Equal(t, http.StatusNotFound, requeue(task.ID))
+}
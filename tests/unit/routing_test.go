@@ -0,0 +1,133 @@
+package unit
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ivanov-nikolay/distributed_calculator/internal/api/http/orchestrator"
+	"github.com/ivanov-nikolay/distributed_calculator/internal/models"
+	"github.com/ivanov-nikolay/distributed_calculator/internal/queue"
+	"github.com/ivanov-nikolay/distributed_calculator/pkg/calculator"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTaskCapability(t *testing.T) {
+	assert.Equal(t, "^", models.Task{Operation: "^"}.Capability())
+	assert.Equal(t, "sqrt", models.Task{Operation: models.OperationCall, Function: "sqrt"}.Capability())
+
+	capabilities := calculator.Capabilities()
+	for _, capability := range []string{"+", "-", "*", "/", "%", "//", "^", "neg", "sqrt", "log", "max"} {
+		assert.Contains(t, capabilities, capability)
+	}
+}
+
+func TestTaskRoutedToCapableAgent(t *testing.T) {
+	orchestrator.SetTaskQueue(queue.NewFIFO())
+
+	id := submit(t, map[string]any{"expression": "14001 ^ 1"})
+
+	// агент, не поддерживающий операцию, задачу не получает
+	_, fetched := fetchTasks(t, "router-basic", "neg, +", 100)
+	_, ok := findTask(fetched, 14001)
+	assert.False(t, ok)
+
+	task, ok := fetchTask(t, "router-power", "neg,^", 14001)
+	if !ok {
+		t.Fatal("task was not routed to capable agent")
+	}
+	assert.Equal(t, http.StatusOK, sendResult(models.TaskResult{ID: task.ID, AgentID: "router-power", Result: 14001}))
+	assert.Equal(t, models.Float(14001), waitExpression(t, id).Result)
+	assert.Equal(t, 0, orchestrator.ReapUnroutableTasks(time.Now().Add(time.Hour)))
+}
+
+func TestUnroutableTaskIsDeadLettered(t *testing.T) {
+	orchestrator.SetTaskQueue(queue.NewFIFO())
+	orchestrator.SetHeartbeatTimeout(500 * time.Millisecond)
+	defer orchestrator.SetHeartbeatTimeout(15 * time.Second)
+	orchestrator.SetRoutingTimeout(100 * time.Millisecond)
+	defer orchestrator.SetRoutingTimeout(time.Minute)
+	orchestrator.SetDeadLetterHold(time.Minute)
+	defer orchestrator.SetDeadLetterHold(0)
+
+	// агенты предыдущих тестов перестают считаться активными
+	time.Sleep(600 * time.Millisecond)
+	_, fetched := fetchTasks(t, "router-busy", "^", 100)
+	assert.Empty(t, fetched)
+	id := submit(t, map[string]any{"expression": "14002 ^ 1"})
+	_, fetched = fetchTasks(t, "router-basic", "neg", 100)
+	_, ok := findTask(fetched, 14002)
+	assert.False(t, ok)
+
+	// пока активен агент, поддерживающий операцию, задача ожидает его в очереди
+	assert.Equal(t, 0, orchestrator.ReapUnroutableTasks(time.Now()))
+	assert.Equal(t, 0, orchestrator.ReapUnroutableTasks(time.Now().Add(200*time.Millisecond)))
+
+	// после того как агент, поддерживающий операцию, перестал быть активным, задача ожидает
+	// не дольше допустимого времени
+	time.Sleep(600 * time.Millisecond)
+	_, fetched = fetchTasks(t, "router-basic", "neg", 100)
+	_, ok = findTask(fetched, 14002)
+	assert.False(t, ok)
+	assert.Equal(t, 0, orchestrator.ReapUnroutableTasks(time.Now()))
+	assert.GreaterOrEqual(t, orchestrator.ReapUnroutableTasks(time.Now().Add(200*time.Millisecond)), 1)
+
+	expr := expressionStatus(t, id)
+	assert.Equal(t, models.StatusExpressionBlocked, expr.Status)
+	assert.True(t, strings.HasSuffix(expr.Reason, `no agent supports operation "^" for 100 ms`), expr.Reason)
+
+	var letter models.DeadLetter
+	for _, l := range deadLetters(t) {
+		if l.Task.ExpressionID == id {
+			letter = l
+		}
+	}
+	assert.Equal(t, models.Float(14002), letter.Task.Arg1)
+	assert.Equal(t, `no agent supports operation "^" for 100 ms`, letter.Reason)
+
+	// после появления агента, поддерживающего операцию, задача возвращается в очередь оператором
+	assert.Equal(t, http.StatusOK, requeue(letter.Task.ID))
+	assert.Equal(t, 0, orchestrator.ReapUnroutableTasks(time.Now().Add(time.Hour)))
+	task, ok := fetchTask(t, "router-power", "^", 14002)
+	if !ok {
+		t.Fatal("requeued task was not routed to capable agent")
+	}
+	assert.Equal(t, http.StatusOK, sendResult(models.TaskResult{ID: task.ID, AgentID: "router-power", Result: 14002}))
+	expr = waitExpression(t, id)
+	assert.Equal(t, models.StatusExpressionCompleted, expr.Status)
+	assert.Equal(t, models.Float(14002), expr.Result)
+}
+
+func TestRefusedTaskKeepsQueuePosition(t *testing.T) {
+	orchestrator.SetTaskQueue(queue.NewFIFO())
+
+	// выражения вычисляются асинхронно, поэтому задачи публикуются по очереди
+	var ids []string
+	for _, expression := range []string{"15101 ^ 1", "15102 + 1", "15103 + 1"} {
+		ids = append(ids, submit(t, map[string]any{"expression": expression}))
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	// агент, не поддерживающий операцию первой задачи, получает следующую, а первая остается в начале очереди
+	_, fetched := fetchTasks(t, "router-plus", "+", 1)
+	if !assert.Len(t, fetched, 1) {
+		t.FailNow()
+	}
+	assert.Equal(t, models.Float(15102), fetched[0].Arg1)
+	plus := fetched[0]
+	_, fetched = fetchTasks(t, "router-any", "", 1)
+	if !assert.Len(t, fetched, 1) {
+		t.FailNow()
+	}
+	assert.Equal(t, models.Float(15101), fetched[0].Arg1)
+	power := fetched[0]
+	last, _ := fetchTask(t, "router-any", "", 15103)
+
+	assert.Equal(t, http.StatusOK, sendResult(models.TaskResult{ID: power.ID, AgentID: "router-any", Result: 15101}))
+	assert.Equal(t, http.StatusOK, sendResult(models.TaskResult{ID: plus.ID, AgentID: "router-plus", Result: 15103}))
+	assert.Equal(t, http.StatusOK, sendResult(models.TaskResult{ID: last.ID, AgentID: "router-any", Result: 15104}))
+	for i, result := range []models.Float{15101, 15103, 15104} {
+		assert.Equal(t, result, waitExpression(t, ids[i]).Result)
+	}
+}
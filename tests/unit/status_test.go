@@ -0,0 +1,137 @@
+package unit
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ivanov-nikolay/distributed_calculator/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// statuses возвращает последовательность статусов из истории переходов выражения
+func statuses(expr models.Expression) []string {
+	var list []string
+	for _, transition := range expr.Transitions {
+		list = append(list, transition.Status)
+	}
+	return list
+}
+
+func TestExpressionTransition(t *testing.T) {
+	start := time.Now()
+	expr := models.NewExpression("1", "1 + 1", start)
+	assert.Equal(t, models.StatusExpressionQueued, expr.Status)
+
+	assert.NoError(t, expr.Transition(models.StatusExpressionParsing, "", start.Add(time.Millisecond)))
+	assert.NoError(t, expr.Transition(models.StatusExpressionRunning, "", start.Add(2*time.Millisecond)))
+	assert.NoError(t, expr.Transition(models.StatusExpressionBlocked, "unknown operation", start.Add(3*time.Millisecond)))
+	assert.Equal(t, "unknown operation", expr.Reason)
+	assert.NoError(t, expr.Transition(models.StatusExpressionRunning, "", start.Add(4*time.Millisecond)))
+	assert.Empty(t, expr.Reason)
+	assert.NoError(t, expr.Transition(models.StatusExpressionCompleted, "", start.Add(5*time.Millisecond)))
+
+	assert.Equal(t, []models.Transition{
+		{Status: models.StatusExpressionQueued, At: start},
+		{Status: models.StatusExpressionParsing, At: start.Add(time.Millisecond)},
+		{Status: models.StatusExpressionRunning, At: start.Add(2 * time.Millisecond)},
+		{Status: models.StatusExpressionBlocked, At: start.Add(3 * time.Millisecond)},
+		{Status: models.StatusExpressionRunning, At: start.Add(4 * time.Millisecond)},
+		{Status: models.StatusExpressionCompleted, At: start.Add(5 * time.Millisecond)},
+	}, expr.Transitions)
+}
+
+func TestExpressionTimedOutTransition(t *testing.T) {
+	// время вычисления может истечь до начала вычисления и пока выражение ожидает задачу
+	// из очереди недоставленных задач
+	for _, from := range []string{models.StatusExpressionQueued, models.StatusExpressionBlocked} {
+		expr := models.Expression{Status: from}
+		assert.NoError(t, expr.Transition(models.StatusExpressionTimedOut, "", time.Now()), from)
+	}
+}
+
+func TestExpressionInvalidTransition(t *testing.T) {
+	tests := []struct {
+		from string
+		to   string
+	}{
+		{models.StatusExpressionQueued, models.StatusExpressionRunning},
+		{models.StatusExpressionQueued, models.StatusExpressionCompleted},
+		{models.StatusExpressionParsing, models.StatusExpressionCompleted},
+		{models.StatusExpressionRunning, models.StatusExpressionQueued},
+		{models.StatusExpressionCompleted, models.StatusExpressionCancelled},
+		{models.StatusExpressionCancelled, models.StatusExpressionFailed},
+		{models.StatusExpressionTimedOut, models.StatusExpressionRunning},
+		{models.StatusExpressionFailed, models.StatusExpressionCompleted},
+		{models.StatusExpressionFailed, models.StatusExpressionRunning},
+		{models.StatusExpressionFailed, models.StatusExpressionCancelled},
+		{models.StatusExpressionBlocked, models.StatusExpressionCompleted},
+	}
+
+	for _, tt := range tests {
+		expr := models.Expression{Status: tt.from}
+		err := expr.Transition(tt.to, "", time.Now())
+		assert.Error(t, err, "%s -> %s", tt.from, tt.to)
+		assert.Equal(t, tt.from, expr.Status)
+		assert.Empty(t, expr.Transitions)
+	}
+}
+
+func TestTerminalStatuses(t *testing.T) {
+	statuses := []string{
+		models.StatusExpressionQueued,
+		models.StatusExpressionParsing,
+		models.StatusExpressionRunning,
+		models.StatusExpressionBlocked,
+		models.StatusExpressionCompleted,
+		models.StatusExpressionFailed,
+		models.StatusExpressionCancelled,
+		models.StatusExpressionTimedOut,
+	}
+	// из конечного статуса переходы недопустимы, из остальных допустим хотя бы один
+	for _, from := range statuses {
+		hasTransition := false
+		for _, to := range statuses {
+			hasTransition = hasTransition || models.CanTransition(from, to)
+		}
+		assert.Equal(t, !models.IsTerminal(from), hasTransition, from)
+	}
+}
+
+func TestExpressionLifecycle(t *testing.T) {
+	stop := make(chan struct{})
+	defer close(stop)
+	go runAgent(stop)
+
+	expr := calculate(t, map[string]any{"expression": "6015.5 + 1"})
+	assert.Equal(t, models.StatusExpressionCompleted, expr.Status)
+	assert.Equal(t, []string{
+		models.StatusExpressionQueued,
+		models.StatusExpressionParsing,
+		models.StatusExpressionRunning,
+		models.StatusExpressionCompleted,
+	}, statuses(expr))
+	for i := 1; i < len(expr.Transitions); i++ {
+		assert.False(t, expr.Transitions[i].At.Before(expr.Transitions[i-1].At))
+	}
+
+	expr = calculate(t, map[string]any{"expression": "6016.5 / 0"})
+	assert.Equal(t, models.StatusExpressionFailed, expr.Status)
+	assert.Equal(t, models.StatusExpressionFailed, statuses(expr)[len(expr.Transitions)-1])
+}
+
+func TestCancelledExpressionLifecycle(t *testing.T) {
+	id := submit(t, map[string]any{"expression": "6017.5 + 1"})
+	if _, ok := fetchTask(t, "", "", 6017.5); !ok {
+		t.Fatal("task was not published")
+	}
+
+	assert.Equal(t, http.StatusOK, cancelExpression(id))
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, []string{
+		models.StatusExpressionQueued,
+		models.StatusExpressionParsing,
+		models.StatusExpressionRunning,
+		models.StatusExpressionCancelled,
+	}, statuses(expressionStatus(t, id)))
+}
@@ -0,0 +1,114 @@
+package unit
+
+import (
+	"math"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ivanov-nikolay/distributed_calculator/internal/api/http/orchestrator"
+	"github.com/ivanov-nikolay/distributed_calculator/internal/models"
+	"github.com/ivanov-nikolay/distributed_calculator/internal/queue"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResultIsAcceptedOnlyFromLeaseHolder(t *testing.T) {
+	orchestrator.SetTaskQueue(queue.NewFIFO())
+
+	id := submit(t, map[string]any{"expression": "11001 + 1"})
+	task, ok := fetchTask(t, "agent-1", "", 11001)
+	if !ok {
+		t.Fatal("task was not published")
+	}
+
+	// результат другого агента и результат без ID агента отклоняются
+	assert.Equal(t, http.StatusForbidden, sendResult(models.TaskResult{ID: task.ID, AgentID: "agent-2", Result: 1}))
+	assert.Equal(t, http.StatusForbidden, sendResult(models.TaskResult{ID: task.ID, Result: 1}))
+	assert.Equal(t, models.StatusExpressionRunning, expressionStatus(t, id).Status)
+
+	result := models.TaskResult{ID: task.ID, AgentID: "agent-1", Result: 11002}
+	assert.Equal(t, http.StatusOK, sendResult(result))
+	expr := waitExpression(t, id)
+	assert.Equal(t, models.StatusExpressionCompleted, expr.Status)
+	assert.Equal(t, models.Float(11002), expr.Result)
+
+	// повторная отправка того же результата идемпотентна, отличающийся результат отклоняется
+	assert.Equal(t, http.StatusOK, sendResult(result))
+	assert.Equal(t, http.StatusConflict, sendResult(models.TaskResult{ID: task.ID, AgentID: "agent-1", Result: 0}))
+	assert.Equal(t, http.StatusConflict, sendResult(models.TaskResult{ID: task.ID, AgentID: "agent-2", Result: 11002}))
+	assert.Equal(t, models.Float(11002), expressionStatus(t, id).Result)
+}
+
+func TestExpiredLeaseHolderResultIsRejected(t *testing.T) {
+	orchestrator.SetTaskQueue(queue.NewFIFO())
+	orchestrator.SetRetryPolicy(3, 0)
+	defer orchestrator.SetRetryPolicy(3, time.Second)
+
+	id := submit(t, map[string]any{"expression": "11003 + 1"})
+	task, ok := fetchTask(t, "agent-1", "", 11003)
+	if !ok {
+		t.Fatal("task was not published")
+	}
+
+	// после истечения аренды задача выдается другому агенту, результат прежнего агента не принимается
+	assert.GreaterOrEqual(t, orchestrator.ReapExpiredTasks(time.Now().Add(time.Hour)), 1)
+	assert.Equal(t, http.StatusForbidden, sendResult(models.TaskResult{ID: task.ID, AgentID: "agent-1", Result: 11004}))
+	redelivered, _ := fetchTask(t, "agent-2", "", 11003)
+	assert.Equal(t, task.ID, redelivered.ID)
+	assert.Equal(t, http.StatusForbidden, sendResult(models.TaskResult{ID: task.ID, AgentID: "agent-1", Result: 11004}))
+
+	assert.Equal(t, http.StatusOK, sendResult(models.TaskResult{ID: task.ID, AgentID: "agent-2", Result: 11004}))
+	assert.Equal(t, models.Float(11004), waitExpression(t, id).Result)
+}
+
+func TestBatchedResultValidation(t *testing.T) {
+	orchestrator.SetTaskQueue(queue.NewFIFO())
+
+	id := submit(t, map[string]any{"expression": "sqrt(11005 - 11006)"})
+	task, ok := fetchTask(t, "agent-1", "", 11005)
+	if !ok {
+		t.Fatal("task was not published")
+	}
+
+	// NaN с ошибкой вычисления повторно отправляется тем же результатом
+	nan := models.TaskResult{ID: task.ID, AgentID: "agent-1", Result: models.Float(math.NaN()), Error: "non-finite"}
+	statuses := sendResults(t, []models.TaskResult{
+		{ID: "unknown", AgentID: "agent-1", Result: 1},
+		{ID: task.ID, AgentID: "agent-2", Result: -1},
+		nan,
+		nan,
+	})
+	assert.Equal(t, []models.TaskResultStatus{
+		{ID: "unknown", Status: http.StatusNotFound},
+		{ID: task.ID, Status: http.StatusForbidden},
+		{ID: task.ID, Status: http.StatusOK},
+		{ID: task.ID, Status: http.StatusOK},
+	}, statuses)
+	assert.Equal(t, models.StatusExpressionFailed, waitExpression(t, id).Status)
+}
+
+func TestLateResultsOfFinishedExpression(t *testing.T) {
+	orchestrator.SetTaskQueue(queue.NewFIFO())
+
+	id := submit(t, map[string]any{"expression": "(11101 / 0) + (11102 + 1)"})
+	var fetched []models.Task
+	for attempt := 0; attempt < 5 && len(fetched) < 2; attempt++ {
+		_, tasks := fetchTasks(t, "agent-late", "", 2)
+		fetched = append(fetched, tasks...)
+	}
+	failing, ok := findTask(fetched, 11101)
+	assert.True(t, ok)
+	pending, ok := findTask(fetched, 11102)
+	assert.True(t, ok)
+
+	failure := models.TaskResult{ID: failing.ID, AgentID: "agent-late", Error: "division by zero"}
+	assert.Equal(t, http.StatusOK, sendResult(failure))
+	assert.Equal(t, models.StatusExpressionFailed, waitExpression(t, id).Status)
+
+	// после завершения выражения повторная отправка принятого результата идемпотентна, отличающийся
+	// результат отклоняется, а результат задачи, снятой с выполнения, принимается без учета
+	assert.Equal(t, http.StatusOK, sendResult(failure))
+	assert.Equal(t, http.StatusConflict, sendResult(models.TaskResult{ID: failing.ID, AgentID: "agent-late", Result: 1}))
+	assert.Equal(t, http.StatusOK, sendResult(models.TaskResult{ID: pending.ID, AgentID: "agent-late", Result: 11103}))
+	assert.Equal(t, models.StatusExpressionFailed, expressionStatus(t, id).Status)
+}
@@ -0,0 +1,78 @@
+package unit
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ivanov-nikolay/distributed_calculator/internal/api/http/orchestrator"
+	"github.com/ivanov-nikolay/distributed_calculator/internal/models"
+	"github.com/ivanov-nikolay/distributed_calculator/internal/queue"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpressionTimeout(t *testing.T) {
+	orchestrator.SetTaskQueue(queue.NewFIFO())
+
+	start := time.Now()
+	id := submit(t, map[string]any{"expression": "(11001 + 1) * 2", "timeout_ms": 100})
+	expr := waitExpression(t, id)
+	assert.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond)
+	assert.Equal(t, models.StatusExpressionTimedOut, expr.Status)
+	assert.Equal(t, 100, expr.TimeoutMS)
+	assert.Equal(t, "expression was not evaluated within 100 ms", expr.Reason)
+
+	// задачи выражения сняты с выполнения
+	w := httptest.NewRecorder()
+	orchestrator.HandleTask(w, httptest.NewRequest(http.MethodGet, "/internal/task", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestExpressionTimeoutDuringParsing(t *testing.T) {
+	// построение графа длинного выражения не укладывается во время вычисления
+	expression := strings.Repeat("1 + ", 100000) + "1"
+	expr := waitExpression(t, submit(t, map[string]any{"expression": expression, "timeout_ms": 1}))
+	assert.Equal(t, models.StatusExpressionTimedOut, expr.Status)
+	assert.Equal(t, "expression was not evaluated within 1 ms", expr.Reason)
+}
+
+func TestExpressionCompletedWithinTimeout(t *testing.T) {
+	stop := make(chan struct{})
+	defer close(stop)
+	go runAgent(stop)
+
+	expr := calculate(t, map[string]any{"expression": "(1 + 2) * 3", "timeout_ms": 5000})
+	assert.Equal(t, models.StatusExpressionCompleted, expr.Status)
+	assert.Equal(t, models.Float(9), expr.Result)
+}
+
+func TestMaxExpressionTimeout(t *testing.T) {
+	orchestrator.SetMaxExpressionTimeout(80 * time.Millisecond)
+	defer orchestrator.SetMaxExpressionTimeout(0)
+
+	// время по умолчанию равно наибольшему, а время, превышающее наибольшее, отклоняется
+	expr := waitExpression(t, submit(t, map[string]any{"expression": "12001 + 1"}))
+	assert.Equal(t, models.StatusExpressionTimedOut, expr.Status)
+	assert.Equal(t, 80, expr.TimeoutMS)
+	w := httptest.NewRecorder()
+	body := bytes.NewReader([]byte(`{"expression": "12002 + 1", "timeout_ms": 81}`))
+	orchestrator.HandleCalculate(w, httptest.NewRequest(http.MethodPost, "/api/v1/calculate", body))
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+	// меньшее время сохраняется
+	id := submit(t, map[string]any{"expression": "12003 + 1", "timeout_ms": 30})
+	assert.Equal(t, 30, expressionStatus(t, id).TimeoutMS)
+}
+
+func TestExpressionTimeoutInvalid(t *testing.T) {
+	// значение, при переводе которого в time.Duration произошло бы переполнение, отклоняется и без наибольшего времени
+	for _, timeout := range []string{"-1", "10000000000000"} {
+		w := httptest.NewRecorder()
+		body := bytes.NewReader([]byte(`{"expression": "1 + 1", "timeout_ms": ` + timeout + `}`))
+		orchestrator.HandleCalculate(w, httptest.NewRequest(http.MethodPost, "/api/v1/calculate", body))
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code, timeout)
+	}
+}
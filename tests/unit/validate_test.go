@@ -0,0 +1,152 @@
+package unit
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/ivanov-nikolay/distributed_calculator/internal/service"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateExpression(t *testing.T) {
+	valid := []string{
+		"2 + 3 * 4",
+		"-(-(1 + 2))",
+		"2 * -3",
+		"2^-1",
+		"sqrt(16) + max(1, 2, 3)",
+		"max(min(1, 2), (3))",
+		"log(8, 2)",
+		"rate * hours + pi",
+		"((1))",
+		"-abs(-x)",
+	}
+
+	for _, expr := range valid {
+		t.Run(expr, func(t *testing.T) {
+			_, err := service.ValidateExpression(expr, map[string]float64{"rate": 1, "hours": 2, "x": 3})
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestValidateExpressionErrors(t *testing.T) {
+	tests := []struct {
+		expr string
+		code string
+		pos  int
+	}{
+		{"", service.ErrorEmptyExpression, 0},
+		{"   ", service.ErrorEmptyExpression, 0},
+		{"2++", service.ErrorUnexpectedEnd, 3},
+		{"2 +* 3", service.ErrorUnexpectedToken, 3},
+		{"(3", service.ErrorUnbalancedParen, 0},
+		{"1 + (2 * (3)", service.ErrorUnbalancedParen, 4},
+		{"3)", service.ErrorUnbalancedParen, 1},
+		{"()", service.ErrorUnexpectedToken, 1},
+		{"2 3", service.ErrorUnexpectedToken, 2},
+		{"2 (3)", service.ErrorUnexpectedToken, 2},
+		{"1, 2", service.ErrorUnexpectedToken, 1},
+		{"(1, 2)", service.ErrorUnexpectedToken, 2},
+		{"max(1,)", service.ErrorUnexpectedToken, 6},
+		{"max(,1)", service.ErrorUnexpectedToken, 4},
+		{"2 $ 3", service.ErrorUnexpectedCharacter, 2},
+		{"1__0", service.ErrorInvalidNumber, 1},
+		{"foo(1)", service.ErrorUnknownFunction, 0},
+		{"sqrt(1, 2)", service.ErrorArgumentCount, 4},
+		{"max()", service.ErrorArgumentCount, 3},
+		{"1 + rate * hours", service.ErrorUnboundIdentifier, 4},
+		{"2 * ", service.ErrorUnexpectedEnd, 4},
+	}
+
+	for _, test := range tests {
+		t.Run(test.expr, func(t *testing.T) {
+			_, err := service.ValidateExpression(test.expr, nil)
+
+			var parseErr *service.ParseError
+			if !errors.As(err, &parseErr) {
+				t.Fatalf("expected ParseError, got %v", err)
+			}
+			if parseErr.Code != test.code || parseErr.Pos != test.pos {
+				t.Errorf("expected %s at %d, got %s at %d (%v)", test.code, test.pos, parseErr.Code, parseErr.Pos, parseErr)
+			}
+		})
+	}
+}
+
+func TestValidateExpressionNestingDepth(t *testing.T) {
+	nested := func(depth int) string {
+		return strings.Repeat("(", depth) + "1" + strings.Repeat(")", depth)
+	}
+
+	_, err := service.ValidateExpression(nested(service.MaxNestingDepth-1), nil)
+	assert.NoError(t, err)
+
+	// слишком глубокая вложенность отклоняется с позицией первой лексемы за пределом, а не переполнением стека
+	for _, expr := range []string{nested(service.MaxNestingDepth), strings.Repeat("(", 1<<20)} {
+		_, err = service.ValidateExpression(expr, nil)
+		var parseErr *service.ParseError
+		if assert.ErrorAs(t, err, &parseErr) {
+			assert.Equal(t, service.ErrorNestingTooDeep, parseErr.Code)
+			assert.Equal(t, service.MaxNestingDepth, parseErr.Pos)
+		}
+	}
+}
+
+func TestValidateExpressionUnboundIdentifiers(t *testing.T) {
+	tests := []struct {
+		expr      string
+		variables map[string]float64
+		message   string
+		pos       int
+	}{
+		{"rate*hours", nil, "unbound identifiers: hours, rate", 0},
+		{"rate*hours+rate", map[string]float64{"hours": 1}, "unbound identifiers: rate", 0},
+		{"1 + y * x + y", nil, "unbound identifiers: x, y", 4},
+		{"sqrt+1", nil, "unbound identifiers: sqrt", 0},
+		{"inf", nil, "unbound identifiers: inf", 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.expr, func(t *testing.T) {
+			_, err := service.ValidateExpression(test.expr, test.variables)
+
+			var parseErr *service.ParseError
+			if !errors.As(err, &parseErr) {
+				t.Fatalf("expected ParseError, got %v", err)
+			}
+			if parseErr.Code != service.ErrorUnboundIdentifier || parseErr.Message != test.message || parseErr.Pos != test.pos {
+				t.Errorf("expected %q at %d, got %s %q at %d", test.message, test.pos, parseErr.Code, parseErr.Message, parseErr.Pos)
+			}
+		})
+	}
+}
+
+func TestResolveIdentifier(t *testing.T) {
+	tests := []struct {
+		name      string
+		variables map[string]float64
+		expected  float64
+		exists    bool
+	}{
+		{"rate", map[string]float64{"rate": 12.5}, 12.5, true},
+		{"pi", nil, math.Pi, true},
+		{"e", nil, math.E, true},
+		{"tau", nil, 2 * math.Pi, true},
+		{"pi", map[string]float64{"pi": 3}, 3, true},
+		{"x", nil, 0, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			value, exists := service.ResolveIdentifier(test.name, test.variables)
+			if value != test.expected || exists != test.exists {
+				t.Errorf("expected %v (%v), got %v (%v)", test.expected, test.exists, value, exists)
+			}
+		})
+	}
+}
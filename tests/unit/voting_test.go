@@ -0,0 +1,194 @@
+package unit
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ivanov-nikolay/distributed_calculator/internal/api/http/orchestrator"
+	"github.com/ivanov-nikolay/distributed_calculator/internal/models"
+	"github.com/ivanov-nikolay/distributed_calculator/internal/queue"
+	"github.com/stretchr/testify/assert"
+)
+
+// quarantine возвращает агентов, находящихся в карантине, по их ID
+func quarantine(t *testing.T) map[string]models.QuarantinedAgent {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	orchestrator.HandleGetQuarantine(w, httptest.NewRequest(http.MethodGet, "/internal/quarantine", nil))
+	var resp map[string][]models.QuarantinedAgent
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response body: %v", err)
+	}
+	agents := make(map[string]models.QuarantinedAgent)
+	for _, agent := range resp["agents"] {
+		agents[agent.AgentID] = agent
+	}
+	return agents
+}
+
+// release выводит агента из карантина и возвращает код ответа
+func release(agent string) int {
+	w := httptest.NewRecorder()
+	orchestrator.HandleReleaseAgent(w, httptest.NewRequest(http.MethodDelete, "/internal/quarantine/"+agent, nil))
+	return w.Code
+}
+
+func TestReplicatedTaskQuorum(t *testing.T) {
+	orchestrator.SetTaskQueue(queue.NewFIFO())
+
+	id := submit(t, map[string]any{"expression": "12001 + 1", "replicas": 3})
+	assert.Equal(t, 3, expressionStatus(t, id).Replicas)
+
+	// каждая реплика задачи выдается отдельному агенту
+	task, ok := fetchTask(t, "voter-1", "", 12001)
+	if !ok {
+		t.Fatal("task was not published")
+	}
+	_, fetched := fetchTasks(t, "voter-1", "", 100)
+	_, ok = findTask(fetched, 12001)
+	assert.False(t, ok)
+	replica, _ := fetchTask(t, "voter-2", "", 12001)
+	assert.Equal(t, task.ID, replica.ID)
+	replica, _ = fetchTask(t, "voter-3", "", 12001)
+	assert.Equal(t, task.ID, replica.ID)
+	_, fetched = fetchTasks(t, "voter-4", "", 100)
+	_, ok = findTask(fetched, 12001)
+	assert.False(t, ok)
+
+	// результат принимается, когда совпадают результаты кворума реплик
+	assert.Equal(t, http.StatusOK, sendResult(models.TaskResult{ID: task.ID, AgentID: "voter-1", Result: 12002}))
+	assert.Equal(t, models.StatusExpressionRunning, expressionStatus(t, id).Status)
+	assert.Equal(t, http.StatusOK, sendResult(models.TaskResult{ID: task.ID, AgentID: "voter-2", Result: 12002}))
+	expr := waitExpression(t, id)
+	assert.Equal(t, models.StatusExpressionCompleted, expr.Status)
+	assert.Equal(t, models.Float(12002), expr.Result)
+
+	assert.Equal(t, http.StatusOK, sendResult(models.TaskResult{ID: task.ID, AgentID: "voter-1", Result: 12002}))
+	assert.Equal(t, http.StatusConflict, sendResult(models.TaskResult{ID: task.ID, AgentID: "voter-1", Result: 1}))
+
+	// агент, запоздавший результат которого разошелся с принятым, помещается в карантин
+	assert.Equal(t, http.StatusOK, sendResult(models.TaskResult{ID: task.ID, AgentID: "voter-3", Result: 1}))
+	assert.Equal(t, models.Float(12002), expressionStatus(t, id).Result)
+	agents := quarantine(t)
+	assert.Equal(t, []string{task.ID}, agents["voter-3"].Tasks)
+	assert.NotContains(t, agents, "voter-1")
+	assert.NotContains(t, agents, "voter-2")
+
+	code, _ := fetchTasks(t, "voter-3", "", 1)
+	assert.Equal(t, http.StatusForbidden, code)
+	assert.Equal(t, http.StatusOK, release("voter-3"))
+	assert.Equal(t, http.StatusNotFound, release("voter-3"))
+	assert.NotContains(t, quarantine(t), "voter-3")
+}
+
+func TestReplicasWithoutQuorum(t *testing.T) {
+	orchestrator.SetTaskQueue(queue.NewFIFO())
+	orchestrator.SetRetryPolicy(3, 0)
+	defer orchestrator.SetRetryPolicy(3, time.Second)
+
+	id := submit(t, map[string]any{"expression": "12003 * 2", "replicas": 2})
+	task, ok := fetchTask(t, "voter-a", "", 12003)
+	if !ok {
+		t.Fatal("task was not published")
+	}
+	replica, _ := fetchTask(t, "voter-b", "", 12003)
+	assert.Equal(t, task.ID, replica.ID)
+
+	// результаты двух реплик разошлись: задача выдается третьему агенту
+	assert.Equal(t, http.StatusOK, sendResult(models.TaskResult{ID: task.ID, AgentID: "voter-a", Result: 24006}))
+	assert.Equal(t, http.StatusOK, sendResult(models.TaskResult{ID: task.ID, AgentID: "voter-b", Result: -1}))
+	assert.Equal(t, models.StatusExpressionRunning, expressionStatus(t, id).Status)
+	_, fetched := fetchTasks(t, "voter-a", "", 100)
+	_, ok = findTask(fetched, 12003)
+	assert.False(t, ok)
+	replica, _ = fetchTask(t, "voter-c", "", 12003)
+	assert.Equal(t, task.ID, replica.ID)
+
+	assert.Equal(t, http.StatusOK, sendResult(models.TaskResult{ID: task.ID, AgentID: "voter-c", Result: 24006}))
+	expr := waitExpression(t, id)
+	assert.Equal(t, models.StatusExpressionCompleted, expr.Status)
+	assert.Equal(t, models.Float(24006), expr.Result)
+
+	agents := quarantine(t)
+	assert.Contains(t, agents, "voter-b")
+	assert.NotContains(t, agents, "voter-a")
+	assert.NotContains(t, agents, "voter-c")
+
+	// результаты агента в карантине не принимаются
+	assert.Equal(t, http.StatusForbidden, sendResult(models.TaskResult{ID: task.ID, AgentID: "voter-b", Result: -1}))
+	assert.Equal(t, http.StatusOK, release("voter-b"))
+}
+
+func TestReplicasNoQuorumExhaustsAttempts(t *testing.T) {
+	orchestrator.SetTaskQueue(queue.NewFIFO())
+	orchestrator.SetRetryPolicy(1, 0)
+	defer orchestrator.SetRetryPolicy(3, time.Second)
+
+	id := submit(t, map[string]any{"expression": "12005 - 1", "replicas": 2})
+	task, ok := fetchTask(t, "voter-x", "", 12005)
+	if !ok {
+		t.Fatal("task was not published")
+	}
+	replica, _ := fetchTask(t, "voter-y", "", 12005)
+	assert.Equal(t, task.ID, replica.ID)
+	assert.Equal(t, http.StatusOK, sendResult(models.TaskResult{ID: task.ID, AgentID: "voter-x", Result: 1}))
+	assert.Equal(t, http.StatusOK, sendResult(models.TaskResult{ID: task.ID, AgentID: "voter-y", Result: 2}))
+
+	expr := waitExpression(t, id)
+	assert.Equal(t, models.StatusExpressionFailed, expr.Status)
+	assert.True(t, strings.HasSuffix(expr.Reason, "replica results did not reach quorum"), expr.Reason)
+	assert.Empty(t, quarantine(t))
+}
+
+func TestInvalidReplicas(t *testing.T) {
+	orchestrator.SetMaxReplicas(5)
+
+	for _, body := range []string{
+		`{"expression": "1 + 1", "replicas": -1}`,
+		`{"expression": "1 + 1", "replicas": 6}`,
+		`{"expression": "1 + 1", "replicas": 1000000000}`,
+	} {
+		w := httptest.NewRecorder()
+		orchestrator.HandleCalculate(w, httptest.NewRequest(http.MethodPost, "/api/v1/calculate", strings.NewReader(body)))
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code, body)
+	}
+}
+
+func TestSkippedReplicaKeepsQueuePosition(t *testing.T) {
+	orchestrator.SetTaskQueue(queue.NewFIFO())
+
+	replicated := submit(t, map[string]any{"expression": "15001 + 1", "replicas": 2})
+	task, ok := fetchTask(t, "keeper-1", "", 15001)
+	if !ok {
+		t.Fatal("task was not published")
+	}
+	// выражения вычисляются асинхронно, поэтому задачи публикуются по очереди
+	second := submit(t, map[string]any{"expression": "15002 + 1"})
+	time.Sleep(50 * time.Millisecond)
+	third := submit(t, map[string]any{"expression": "15003 + 1"})
+	time.Sleep(50 * time.Millisecond)
+
+	// агент, уже получивший реплику, пропускает задачу, но она остается в начале очереди
+	next, ok := fetchTask(t, "keeper-1", "", 15002)
+	if !ok {
+		t.Fatal("task was not published")
+	}
+	_, fetched := fetchTasks(t, "keeper-2", "", 1)
+	if assert.Len(t, fetched, 1) {
+		assert.Equal(t, task.ID, fetched[0].ID)
+	}
+	last, _ := fetchTask(t, "keeper-2", "", 15003)
+
+	assert.Equal(t, http.StatusOK, sendResult(models.TaskResult{ID: task.ID, AgentID: "keeper-1", Result: 15002}))
+	assert.Equal(t, http.StatusOK, sendResult(models.TaskResult{ID: task.ID, AgentID: "keeper-2", Result: 15002}))
+	assert.Equal(t, http.StatusOK, sendResult(models.TaskResult{ID: next.ID, AgentID: "keeper-1", Result: 15003}))
+	assert.Equal(t, http.StatusOK, sendResult(models.TaskResult{ID: last.ID, AgentID: "keeper-2", Result: 15004}))
+	assert.Equal(t, models.Float(15002), waitExpression(t, replicated).Result)
+	assert.Equal(t, models.Float(15003), waitExpression(t, second).Result)
+	assert.Equal(t, models.Float(15004), waitExpression(t, third).Result)
+}